@@ -0,0 +1,219 @@
+package lfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+
+	comp "github.com/txaty/go-bigcomplex"
+)
+
+// certificatePrimalityRounds is the number of Miller-Rabin rounds with which
+// VerifyCertificate checks the prime of a certificate, in addition to Baillie-PSW.
+const certificatePrimalityRounds = 20
+
+// ErrInvalidCertificate is returned by VerifyCertificate for a certificate whose
+// witnesses do not derive its result.
+var ErrInvalidCertificate = errors.New("lfs: invalid certificate")
+
+// Certificate records how a four-square representation was derived, so that the
+// derivation can be checked independently of the randomized search that found it.
+type Certificate struct {
+	// N is the represented integer.
+	N *big.Int `json:"n"`
+	// E and NOdd factor N = 2^E * NOdd with an odd NOdd. Both are 0 for N = 0.
+	E    int      `json:"e"`
+	NOdd *big.Int `json:"n_odd"`
+	// Path is PathPrecomputed for an NOdd taken from the built-in table of small
+	// values, and PathBasic or PathFCM for an NOdd found by a search.
+	Path SolvePath `json:"path"`
+	// P is the prime found by the search, S satisfies S^2 = -1 (mod P), and
+	// A + B*i is a Gaussian GCD of S + i or S - i and P, so that P = A^2 + B^2. In the
+	// basic algorithm, P + 1 is a multiple of NOdd. They are nil for PathPrecomputed.
+	P *big.Int `json:"p,omitempty"`
+	S *big.Int `json:"s,omitempty"`
+	A *big.Int `json:"a,omitempty"`
+	B *big.Int `json:"b,omitempty"`
+	// L is the odd integer with P = 2*NOdd - L^2 of the FCM algorithm, and nil
+	// otherwise.
+	L *big.Int `json:"l,omitempty"`
+	// FastGCDThreshold is the threshold of the solver that selected the Hurwitz
+	// GCRD algorithm. The GCRD is only unique up to units, so the replay has to
+	// use the same algorithm to arrive at the same result.
+	FastGCDThreshold int `json:"fast_gcd_threshold"`
+	// Result is the four-square representation of N.
+	Result FourInt `json:"result"`
+}
+
+// SolveWithCertificate computes the representation of n with the basic algorithm
+// below FCMThreshold and the FCM algorithm from it on, and returns it together
+// with the witnesses of its derivation. Unlike Solve, it neither uses the native
+// solver nor the result cache nor hedging, as those do not produce witnesses.
+// Budgets, cancellation and WithSelfVerify apply as in SolveWithStats, the latter
+// checking the whole certificate with VerifyCertificate.
+func (s *Solver) SolveWithCertificate(ctx context.Context, n *big.Int) (*Certificate, error) {
+	if n.Sign() < 0 {
+		return nil, ErrNegativeInput
+	}
+	if s.TimeBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, s.TimeBudget, ErrSearchExhausted)
+		defer cancel()
+	}
+	for retry := 0; ; retry++ {
+		c, err := s.certify(ctx, n)
+		if err != nil || !s.SelfVerify {
+			return c, err
+		}
+		err = VerifyCertificate(c)
+		if err == nil {
+			return c, nil
+		}
+		s.stats.verifyFailures.Add(1)
+		s.logger().Error("lfs: certificate failed verification", slog.Int("bits", n.BitLen()), slog.Int("retry", retry), slog.Any("error", err))
+		if retry >= s.SelfVerifyRetries {
+			return nil, fmt.Errorf("%w after %d retries: %w", ErrVerificationFailed, retry, err)
+		}
+	}
+}
+
+// certify computes the certificate of a non-negative n.
+func (s *Solver) certify(ctx context.Context, n *big.Int) (*Certificate, error) {
+	c := &Certificate{
+		N:                new(big.Int).Set(n),
+		NOdd:             new(big.Int),
+		Path:             PathPrecomputed,
+		FastGCDThreshold: s.FastGCDThreshold,
+	}
+	if n.Sign() == 0 {
+		c.Result = NewFourInt(precomputedHurwitzGCRDs[0].ValInt())
+		return c, nil
+	}
+	c.NOdd, c.E = extractOddComponent(n)
+	var (
+		gcrd *comp.HurwitzInt
+		res  searchResult
+		err  error
+	)
+	switch {
+	case c.NOdd.Cmp(bigPrecomputeLmt) <= 0:
+		s.observePath(n, PathPrecomputed)
+		gcrd = precomputedHurwitzGCRDs[c.NOdd.Int64()]
+	case n.Cmp(s.FCMThreshold) < 0:
+		c.Path = PathBasic
+		s.observePath(n, PathBasic)
+		if res, _, err = s.searchBasic(ctx, c.NOdd, s.NumRoutines); err != nil {
+			return nil, err
+		}
+		gcrd = finalizeHurwitzGCRD(c.NOdd, res.gcd, s.FastGCDThreshold)
+	default:
+		c.Path = PathFCM
+		s.observePath(n, PathFCM)
+		if res, _, err = s.fcmRandTrail(ctx, c.NOdd, s.NumRoutines); err != nil {
+			return nil, err
+		}
+		c.L = new(big.Int).Set(res.l)
+		gcrd = fcmFinalizeHurwitzGCRD(c.NOdd, res.l, res.gcd, s.FastGCDThreshold)
+	}
+	if res.gcd != nil {
+		c.P = new(big.Int).Set(res.p)
+		c.S = new(big.Int).Set(res.s)
+		c.A = new(big.Int).Set(res.gcd.R)
+		c.B = new(big.Int).Set(res.gcd.I)
+	}
+	c.Result = composeFourInt(c.E, gcrd)
+	return c, nil
+}
+
+// VerifyCertificate checks that the witnesses of c derive its result. It checks
+// that N = 2^E * NOdd, that P is prime and derived from NOdd as the path of c
+// prescribes, that S^2 = -1 (mod P) and that A + B*i is a Gaussian prime of norm
+// P dividing S + i or S - i. It then replays the Hurwitz GCRD and the composition with
+// (1+i)^E, which are deterministic, and requires the replay to equal the result
+// of c and the result to represent N. It returns nil or an error wrapping
+// ErrInvalidCertificate.
+func VerifyCertificate(c *Certificate) error {
+	if c == nil || c.N == nil || c.NOdd == nil {
+		return fmt.Errorf("%w: missing integer", ErrInvalidCertificate)
+	}
+	// E is bounded by the bit length of N before 2^E is computed.
+	if c.N.Sign() < 0 || c.E < 0 || c.E > c.N.BitLen() || c.NOdd.Sign() < 0 || c.N.Sign() > 0 && c.NOdd.Bit(0) == 0 {
+		return fmt.Errorf("%w: N = 2^E * NOdd is not a factorization with odd NOdd", ErrInvalidCertificate)
+	}
+	if c.N.Cmp(new(big.Int).Lsh(c.NOdd, uint(c.E))) != 0 {
+		return fmt.Errorf("%w: N != 2^E * NOdd", ErrInvalidCertificate)
+	}
+	var gcrd *comp.HurwitzInt
+	switch c.Path {
+	case PathPrecomputed:
+		if c.NOdd.Cmp(bigPrecomputeLmt) > 0 {
+			return fmt.Errorf("%w: NOdd %v is not precomputed", ErrInvalidCertificate, c.NOdd)
+		}
+		gcrd = precomputedHurwitzGCRDs[c.NOdd.Int64()]
+	case PathBasic:
+		if err := verifyWitnesses(c); err != nil {
+			return err
+		}
+		if r := new(big.Int).Add(c.P, big1); r.Mod(r, c.NOdd).Sign() != 0 {
+			return fmt.Errorf("%w: P + 1 is not a multiple of NOdd", ErrInvalidCertificate)
+		}
+		gcrd = finalizeHurwitzGCRD(c.NOdd, comp.NewGaussianInt(c.A, c.B), c.FastGCDThreshold)
+	case PathFCM:
+		if err := verifyWitnesses(c); err != nil {
+			return err
+		}
+		if c.L == nil || c.L.Sign() <= 0 || c.L.Bit(0) == 0 {
+			return fmt.Errorf("%w: L is not a positive odd integer", ErrInvalidCertificate)
+		}
+		p := new(big.Int).Mul(c.L, c.L)
+		if p.Sub(new(big.Int).Lsh(c.NOdd, 1), p).Cmp(c.P) != 0 {
+			return fmt.Errorf("%w: P != 2*NOdd - L^2", ErrInvalidCertificate)
+		}
+		gcrd = fcmFinalizeHurwitzGCRD(c.NOdd, c.L, comp.NewGaussianInt(c.A, c.B), c.FastGCDThreshold)
+	default:
+		return fmt.Errorf("%w: unknown path %v", ErrInvalidCertificate, c.Path)
+	}
+	if want := composeFourInt(c.E, gcrd); !want.Equal(c.Result) {
+		return fmt.Errorf("%w: result %v differs from the replayed derivation %v", ErrInvalidCertificate, c.Result.String(), want.String())
+	}
+	if !Verify(c.N, c.Result) {
+		return fmt.Errorf("%w: result does not represent N", ErrInvalidCertificate)
+	}
+	return nil
+}
+
+// verifyWitnesses checks the witnesses shared by the basic and the FCM algorithm:
+// P is prime, S^2 = -1 (mod P), A^2 + B^2 = P and A + B*i divides S + i or, as
+// found by the Cornacchia split, S - i. A + B*i divides S + i if
+// (S + i)(A - B*i) = (S*A + B) + (A - S*B)*i is a multiple of P, and S - i if
+// (S - i)(A - B*i) = (S*A - B) - (A + S*B)*i is.
+func verifyWitnesses(c *Certificate) error {
+	if c.NOdd.Sign() == 0 {
+		return fmt.Errorf("%w: witnesses for N = 0", ErrInvalidCertificate)
+	}
+	if c.P == nil || c.S == nil || c.A == nil || c.B == nil {
+		return fmt.Errorf("%w: missing witness", ErrInvalidCertificate)
+	}
+	if c.P.Sign() <= 0 || !c.P.ProbablyPrime(certificatePrimalityRounds) {
+		return fmt.Errorf("%w: P is not prime", ErrInvalidCertificate)
+	}
+	if !isSqrtMinusOne(c.S, c.P) {
+		return fmt.Errorf("%w: S^2 != -1 (mod P)", ErrInvalidCertificate)
+	}
+	norm := new(big.Int).Mul(c.A, c.A)
+	if norm.Add(norm, new(big.Int).Mul(c.B, c.B)).Cmp(c.P) != 0 {
+		return fmt.Errorf("%w: A^2 + B^2 != P", ErrInvalidCertificate)
+	}
+	sa := new(big.Int).Mul(c.S, c.A)
+	sb := new(big.Int).Mul(c.S, c.B)
+	divides := func(re, im *big.Int) bool {
+		return re.Mod(re, c.P).Sign() == 0 && im.Mod(im, c.P).Sign() == 0
+	}
+	if !divides(new(big.Int).Add(sa, c.B), new(big.Int).Sub(c.A, sb)) &&
+		!divides(new(big.Int).Sub(sa, c.B), new(big.Int).Add(c.A, sb)) {
+		return fmt.Errorf("%w: A + B*i divides neither S + i nor S - i", ErrInvalidCertificate)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package lfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestSolveWithCertificate(t *testing.T) {
+	large := new(big.Int).Sub(new(big.Int).Lsh(big1, 256), big.NewInt(189))
+	tests := []struct {
+		name string
+		n    *big.Int
+		opts []Option
+		path SolvePath
+	}{
+		{name: "zero", n: big.NewInt(0), path: PathPrecomputed},
+		{name: "precomputed", n: big.NewInt(19 << 10), path: PathPrecomputed},
+		{name: "basic small", n: big.NewInt(12345), path: PathBasic},
+		{name: "basic native range", n: new(big.Int).Lsh(big.NewInt(1<<40+1), 3), path: PathBasic},
+		{name: "basic", n: large, path: PathBasic},
+		{name: "basic euclid", n: large, opts: []Option{WithFastGCDThreshold(0)}, path: PathBasic},
+		{name: "fcm", n: large, opts: []Option{WithFCMThreshold(big1)}, path: PathFCM},
+		{name: "fcm lehmer", n: large, opts: []Option{WithFCMThreshold(big1), WithFastGCDThreshold(1)}, path: PathFCM},
+		{name: "self-verify", n: large, opts: []Option{WithSelfVerify(1)}, path: PathBasic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewSolver(tt.opts...).SolveWithCertificate(context.Background(), tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Path != tt.path || !Verify(tt.n, c.Result) {
+				t.Fatalf("SolveWithCertificate() path = %v, result = %v", c.Path, c.Result.String())
+			}
+			if err := VerifyCertificate(c); err != nil {
+				t.Fatalf("VerifyCertificate() = %v", err)
+			}
+			data, err := json.Marshal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Certificate
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyCertificate(&decoded); err != nil {
+				t.Errorf("VerifyCertificate() of %s = %v", data, err)
+			}
+		})
+	}
+
+	if _, err := NewSolver().SolveWithCertificate(context.Background(), big.NewInt(-1)); !errors.Is(err, ErrNegativeInput) {
+		t.Errorf("SolveWithCertificate(-1) error = %v, want %v", err, ErrNegativeInput)
+	}
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	n := new(big.Int).Sub(new(big.Int).Lsh(big1, 256), big.NewInt(189))
+	basic, err := NewSolver().SolveWithCertificate(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcm, err := NewSolver(WithFCMThreshold(big1)).SolveWithCertificate(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := func(c *Certificate) *Certificate {
+		d := *c
+		d.Result = c.Result.Clone()
+		return &d
+	}
+	tests := []struct {
+		name   string
+		base   *Certificate
+		tamper func(c *Certificate)
+	}{
+		{name: "nil N", base: basic, tamper: func(c *Certificate) { c.N = nil }},
+		{name: "E", base: basic, tamper: func(c *Certificate) { c.E = 1 }},
+		{name: "huge E", base: basic, tamper: func(c *Certificate) { c.E = 1 << 40 }},
+		{name: "NOdd", base: basic, tamper: func(c *Certificate) { c.NOdd = new(big.Int).Add(n, big2) }},
+		{name: "path", base: basic, tamper: func(c *Certificate) { c.Path = PathFCM }},
+		{name: "unknown path", base: basic, tamper: func(c *Certificate) { c.Path = PathHedged }},
+		{name: "missing witness", base: basic, tamper: func(c *Certificate) { c.S = nil }},
+		{name: "composite P", base: basic, tamper: func(c *Certificate) { c.P = new(big.Int).Mul(c.P, big3) }},
+		{name: "S", base: basic, tamper: func(c *Certificate) { c.S = new(big.Int).Add(c.S, big1) }},
+		{name: "A and B", base: basic, tamper: func(c *Certificate) { c.A, c.B = fcm.A, fcm.B }},
+		{name: "result", base: basic, tamper: func(c *Certificate) { c.Result = NewFourInt(big1, big1, big1, big1) }},
+		// A valid representation of N that was not derived from the witnesses.
+		{name: "other representation", base: basic, tamper: func(c *Certificate) { c.Result = fcm.Result.Clone() }},
+		{name: "L", base: fcm, tamper: func(c *Certificate) { c.L = new(big.Int).Add(c.L, big2) }},
+		{name: "fcm as basic", base: fcm, tamper: func(c *Certificate) { c.Path = PathBasic }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clone(tt.base)
+			tt.tamper(c)
+			if err := VerifyCertificate(c); !errors.Is(err, ErrInvalidCertificate) {
+				t.Errorf("VerifyCertificate() = %v, want %v", err, ErrInvalidCertificate)
+			}
+			if err := VerifyCertificate(tt.base); err != nil {
+				t.Errorf("VerifyCertificate() of the original = %v", err)
+			}
+		})
+	}
+	if err := VerifyCertificate(nil); !errors.Is(err, ErrInvalidCertificate) {
+		t.Errorf("VerifyCertificate(nil) = %v, want %v", err, ErrInvalidCertificate)
+	}
+}
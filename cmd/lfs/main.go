@@ -0,0 +1,164 @@
+// Command lfs computes Lagrange four-square representations of integers given as
+// arguments or, without arguments, as lines on stdin.
+//
+// Integers are given in decimal, as 0x-prefixed hexadecimal, as base64 of their
+// big-endian bytes after a "base64:" prefix, or as expressions of such numbers
+// with +, -, *, ^ and parentheses, for example 2^1024+7. Empty lines and lines
+// starting with # are skipped.
+//
+// Usage:
+//
+//	lfs [-fcm-threshold 2^500] [-routines 4] [-seed 1] [-timeout 10s] [-verify] [-format text|json] [integer ...]
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/txaty/lfs"
+)
+
+// maxLineBytes bounds the length of an input line on stdin.
+const maxLineBytes = 1 << 24
+
+// Result is the JSON line written for every input with -format json.
+type Result struct {
+	Input     string     `json:"input"`
+	N         string     `json:"n,omitempty"`
+	Squares   *[4]string `json:"squares,omitempty"`
+	Verified  *bool      `json:"verified,omitempty"`
+	ElapsedNs int64      `json:"elapsed_ns,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command with the given arguments and streams and returns the
+// exit code: 0 on success, 1 if any input failed, and 2 for invalid usage.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lfs", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var (
+		threshold = flags.String("fcm-threshold", "", "threshold from which the FCM algorithm is used, e.g. 2^500 (default 2^500)")
+		routines  = flags.Int("routines", runtime.NumCPU(), "number of goroutines of the randomized search")
+		seed      = flags.Uint64("seed", 0, "seed of the randomized search, reproducible with -routines 1 (default random)")
+		timeout   = flags.Duration("timeout", 0, "time limit per input, 0 for none")
+		verify    = flags.Bool("verify", false, "verify every representation")
+		format    = flags.String("format", "text", "output format: text or json (JSON lines)")
+	)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "lfs: unknown format %q\n", *format)
+		return 2
+	}
+	opts := []lfs.Option{lfs.WithNumRoutines(*routines), lfs.WithSeed(*seed), lfs.WithTimeBudget(*timeout)}
+	if *threshold != "" {
+		th, err := parseInt(*threshold)
+		if err != nil {
+			fmt.Fprintf(stderr, "lfs: invalid -fcm-threshold: %v\n", err)
+			return 2
+		}
+		opts = append(opts, lfs.WithFCMThreshold(th))
+	}
+	solver := lfs.NewSolver(opts...)
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	code := 0
+	solve := func(input string) {
+		res := solveInput(solver, input, *verify)
+		if res.Error != "" || res.Verified != nil && !*res.Verified {
+			code = 1
+		}
+		if err := writeResult(out, stderr, *format, res); err != nil {
+			fmt.Fprintf(stderr, "lfs: %v\n", err)
+			code = 1
+		}
+		// Flush after every input, so that results of a stream show up as they are computed.
+		out.Flush()
+	}
+	if flags.NArg() > 0 {
+		for _, arg := range flags.Args() {
+			solve(arg)
+		}
+		return code
+	}
+	sc := bufio.NewScanner(stdin)
+	sc.Buffer(nil, maxLineBytes)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		solve(line)
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintf(stderr, "lfs: reading stdin: %v\n", err)
+		return 1
+	}
+	return code
+}
+
+// solveInput parses and solves a single input.
+func solveInput(solver *lfs.Solver, input string, verify bool) Result {
+	res := Result{Input: input}
+	n, err := parseInt(input)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.N = n.String()
+	start := time.Now()
+	fi, _, err := solver.SolveWithStats(context.Background(), n)
+	res.ElapsedNs = time.Since(start).Nanoseconds()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Squares = &[4]string{fi[0].String(), fi[1].String(), fi[2].String(), fi[3].String()}
+	if verify {
+		ok := lfs.Verify(n, fi)
+		res.Verified = &ok
+	}
+	return res
+}
+
+// writeResult writes res in the given format. In the text format, errors go to
+// stderr and representations to w as n = a^2 + b^2 + c^2 + d^2.
+func writeResult(w, stderr io.Writer, format string, res Result) error {
+	if format == "json" {
+		b, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+	if res.Error != "" {
+		_, err := fmt.Fprintf(stderr, "lfs: %s: %s\n", res.Input, res.Error)
+		return err
+	}
+	s := res.Squares
+	line := fmt.Sprintf("%s = %s^2 + %s^2 + %s^2 + %s^2", res.N, s[0], s[1], s[2], s[3])
+	if res.Verified != nil {
+		if *res.Verified {
+			line += " (verified)"
+		} else {
+			line += " (VERIFICATION FAILED)"
+		}
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestParseInt(t *testing.T) {
+	pow := func(e uint) *big.Int { return new(big.Int).Lsh(big.NewInt(1), e) }
+	tests := []struct {
+		in      string
+		want    *big.Int
+		wantErr bool
+	}{
+		{in: "12345", want: big.NewInt(12345)},
+		{in: " 0x1F ", want: big.NewInt(31)},
+		{in: "base64:AQA=", want: big.NewInt(256)},
+		{in: "base64:AQA", want: big.NewInt(256)},
+		{in: "2^1024+7", want: new(big.Int).Add(pow(1024), big.NewInt(7))},
+		{in: "2^2^3", want: big.NewInt(256)},
+		{in: "-2^2", want: big.NewInt(-4)},
+		{in: "(2 + 3) * 4 - 1", want: big.NewInt(19)},
+		{in: "0x10^2*3", want: big.NewInt(768)},
+		{in: "2^x", wantErr: true},
+		{in: "2^", wantErr: true},
+		{in: "(1", wantErr: true},
+		{in: "1 2", wantErr: true},
+		{in: "2^-1", wantErr: true},
+		{in: "10^10^10", wantErr: true},
+		{in: "base64:%%", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseInt(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInt(%q) error = %v, wantErr %t", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Cmp(tt.want) != 0 {
+				t.Errorf("parseInt(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	stdin := strings.NewReader("# comment\n2^300+1\n\n12345\nnot a number\n")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-format", "json", "-verify", "-routines", "1", "-seed", "1"}, stdin, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("run() = %d, want 1 for the invalid input", code)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("run() wrote %d lines, want 3:\n%s", len(lines), stdout.String())
+	}
+	for i, line := range lines {
+		var res Result
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			t.Fatal(err)
+		}
+		if wantErr := i == 2; (res.Error != "") != wantErr || !wantErr && (res.Verified == nil || !*res.Verified) {
+			t.Errorf("line %d = %s", i, line)
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-fcm-threshold", "2^8", "7"}, nil, &stdout, &stderr); code != 0 {
+		t.Errorf("run() = %d, want 0", code)
+	}
+	if got, want := stdout.String(), "7 = 2^2 + 1^2 + 1^2 + 1^2\n"; got != want {
+		t.Errorf("run() wrote %q, want %q", got, want)
+	}
+	if code := run([]string{"-format", "xml", "7"}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("run() = %d, want 2 for an unknown format", code)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// maxExprBits bounds the bit length of powers in expressions, so that a typo such
+// as 2^10^10 fails instead of exhausting memory.
+const maxExprBits = 1 << 24
+
+// base64Prefix marks an input given as base64 of its big-endian bytes.
+const base64Prefix = "base64:"
+
+// parseInt parses an integer given in decimal, as 0x-prefixed hexadecimal, as
+// base64 of its big-endian bytes after a "base64:" prefix, or as an expression
+// of such numbers with +, -, *, ^ and parentheses, for example 2^1024+7.
+func parseInt(s string) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	if rest, ok := strings.CutPrefix(s, base64Prefix); ok {
+		b, err := base64.StdEncoding.DecodeString(rest)
+		if err != nil {
+			if b, err = base64.RawStdEncoding.DecodeString(rest); err != nil {
+				return nil, fmt.Errorf("invalid base64 %q: %w", rest, err)
+			}
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	p := &exprParser{s: s}
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return x, nil
+}
+
+// exprParser is a recursive descent parser of integer expressions:
+//
+//	expr    = term {("+" | "-") term}
+//	term    = unary {"*" unary}
+//	unary   = "-" unary | power
+//	power   = primary ["^" unary]
+//	primary = number | "(" expr ")"
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid input %q at offset %d: %s", p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// accept consumes the operator op if it is next.
+func (p *exprParser) accept(op byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expr() (*big.Int, error) {
+	x, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept('+'):
+			y, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			x.Add(x, y)
+		case p.accept('-'):
+			y, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			x.Sub(x, y)
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *exprParser) term() (*big.Int, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept('*') {
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x.Mul(x, y)
+	}
+	return x, nil
+}
+
+func (p *exprParser) power() (*big.Int, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept('^') {
+		return x, nil
+	}
+	e, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if e.Sign() < 0 {
+		return nil, p.errorf("negative exponent %v", e)
+	}
+	// |x|^e has more than e*(bitLen(|x|)-1) bits; 0 and 1 stay small for any e.
+	if x.CmpAbs(big.NewInt(1)) > 0 && (!e.IsInt64() || e.Int64() > maxExprBits/int64(x.BitLen()-1)) {
+		return nil, p.errorf("power %v^%v exceeds %d bits", x, e, maxExprBits)
+	}
+	return x.Exp(x, e, nil), nil
+}
+
+func (p *exprParser) unary() (*big.Int, error) {
+	if p.accept('-') {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return x.Neg(x), nil
+	}
+	return p.power()
+}
+
+func (p *exprParser) primary() (*big.Int, error) {
+	if p.accept('(') {
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, p.errorf("missing )")
+		}
+		return x, nil
+	}
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && isNumberByte(p.s[p.pos]) {
+		p.pos++
+	}
+	lit := p.s[start:p.pos]
+	if lit == "" {
+		if p.pos == len(p.s) {
+			return nil, p.errorf("unexpected end of input")
+		}
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	base, digits := 10, lit
+	if len(lit) > 2 && (lit[:2] == "0x" || lit[:2] == "0X") {
+		base, digits = 16, lit[2:]
+	}
+	x, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("invalid number %q", lit)
+	}
+	return x, nil
+}
+
+// isNumberByte reports whether c may appear in a decimal or hexadecimal literal.
+func isNumberByte(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' || c == 'x' || c == 'X'
+}
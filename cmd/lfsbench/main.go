@@ -0,0 +1,231 @@
+// Command lfsbench benchmarks the lfs solvers across bit lengths and goroutine
+// counts and writes machine-readable reports, so that performance can be tracked
+// between releases.
+//
+// Usage:
+//
+//	lfsbench [-bits 512,1024,2048] [-routines 1,4] [-algos solve,basic,fcm,hedged] [-iters 20] [-format json|csv|text] [-o file]
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/txaty/lfs"
+)
+
+// algorithms maps the names accepted by -algos to the solver configuration they benchmark.
+var algorithms = map[string]func(routines int) (*lfs.Solver, func(*lfs.Solver, *big.Int) lfs.FourInt){
+	// solve uses the default path selection of Solver.Solve.
+	"solve": func(routines int) (*lfs.Solver, func(*lfs.Solver, *big.Int) lfs.FourInt) {
+		return lfs.NewSolver(lfs.WithNumRoutines(routines)), (*lfs.Solver).Solve
+	},
+	// basic always uses the basic algorithm.
+	"basic": func(routines int) (*lfs.Solver, func(*lfs.Solver, *big.Int) lfs.FourInt) {
+		return lfs.NewSolver(lfs.WithNumRoutines(routines)), (*lfs.Solver).SolveBasic
+	},
+	// fcm always uses the FCM algorithm by setting the threshold to 1.
+	"fcm": func(routines int) (*lfs.Solver, func(*lfs.Solver, *big.Int) lfs.FourInt) {
+		return lfs.NewSolver(lfs.WithNumRoutines(routines), lfs.WithFCMThreshold(big.NewInt(1))), (*lfs.Solver).Solve
+	},
+	// hedged races the basic and the FCM algorithm on every input.
+	"hedged": func(routines int) (*lfs.Solver, func(*lfs.Solver, *big.Int) lfs.FourInt) {
+		return lfs.NewSolver(lfs.WithNumRoutines(routines), lfs.WithHedging(1<<16)), (*lfs.Solver).Solve
+	},
+}
+
+// Result holds the measurements of one algorithm, bit length and goroutine count.
+type Result struct {
+	Algorithm            string  `json:"algorithm"`
+	Bits                 int     `json:"bits"`
+	Routines             int     `json:"routines"`
+	Iterations           int     `json:"iterations"`
+	MeanNs               int64   `json:"mean_ns"`
+	P50Ns                int64   `json:"p50_ns"`
+	P90Ns                int64   `json:"p90_ns"`
+	P99Ns                int64   `json:"p99_ns"`
+	MaxNs                int64   `json:"max_ns"`
+	AllocsPerOp          uint64  `json:"allocs_per_op"`
+	BytesPerOp           uint64  `json:"bytes_per_op"`
+	CandidatesPerSuccess float64 `json:"candidates_per_success"`
+}
+
+// Report is the top-level JSON document written by lfsbench.
+type Report struct {
+	Timestamp time.Time `json:"timestamp"`
+	GoVersion string    `json:"go_version"`
+	GOOS      string    `json:"goos"`
+	GOARCH    string    `json:"goarch"`
+	NumCPU    int       `json:"num_cpu"`
+	Results   []Result  `json:"results"`
+}
+
+func main() {
+	var (
+		bitsFlag     = flag.String("bits", "256,512,1024,2048", "comma-separated bit lengths of the inputs")
+		routinesFlag = flag.String("routines", strconv.Itoa(runtime.NumCPU()), "comma-separated NumRoutines values")
+		algosFlag    = flag.String("algos", "solve,basic,fcm", "comma-separated algorithms: solve, basic, fcm, hedged")
+		iters        = flag.Int("iters", 20, "number of solves per configuration")
+		format       = flag.String("format", "json", "output format: json, csv or text")
+		out          = flag.String("o", "", "output file (default stdout)")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("lfsbench: ")
+
+	bitLens, err := parseInts(*bitsFlag)
+	if err != nil {
+		log.Fatalf("invalid -bits: %v", err)
+	}
+	routines, err := parseInts(*routinesFlag)
+	if err != nil {
+		log.Fatalf("invalid -routines: %v", err)
+	}
+	algos := strings.Split(*algosFlag, ",")
+	for _, a := range algos {
+		if _, ok := algorithms[a]; !ok {
+			log.Fatalf("unknown algorithm %q", a)
+		}
+	}
+	if *iters < 1 {
+		log.Fatal("-iters must be positive")
+	}
+	write, ok := writers[*format]
+	if !ok {
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	report := Report{
+		Timestamp: time.Now().UTC(),
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		NumCPU:    runtime.NumCPU(),
+	}
+	for _, bits := range bitLens {
+		inputs, err := randomInputs(bits, *iters)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, r := range routines {
+			for _, a := range algos {
+				res, err := run(a, bits, r, inputs)
+				if err != nil {
+					log.Fatal(err)
+				}
+				report.Results = append(report.Results, res)
+			}
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := write(w, report); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run benchmarks one configuration on the given inputs.
+func run(algo string, bits, routines int, inputs []*big.Int) (Result, error) {
+	solver, solve := algorithms[algo](routines)
+	// Warm up the caches shared by all solves.
+	solve(solver, inputs[0])
+	solver.ResetStats()
+
+	latencies := make([]time.Duration, len(inputs))
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i, n := range inputs {
+		start := time.Now()
+		fi := solve(solver, n)
+		latencies[i] = time.Since(start)
+		if !lfs.Verify(n, fi) {
+			return Result{}, fmt.Errorf("%s: result %v does not verify for %v", algo, fi, n)
+		}
+	}
+	runtime.ReadMemStats(&after)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	iters := uint64(len(inputs))
+	return Result{
+		Algorithm:            algo,
+		Bits:                 bits,
+		Routines:             routines,
+		Iterations:           len(inputs),
+		MeanNs:               int64(total) / int64(len(inputs)),
+		P50Ns:                int64(percentile(latencies, 0.50)),
+		P90Ns:                int64(percentile(latencies, 0.90)),
+		P99Ns:                int64(percentile(latencies, 0.99)),
+		MaxNs:                int64(latencies[len(latencies)-1]),
+		AllocsPerOp:          (after.Mallocs - before.Mallocs) / iters,
+		BytesPerOp:           (after.TotalAlloc - before.TotalAlloc) / iters,
+		CandidatesPerSuccess: float64(solver.Stats().Candidates) / float64(iters),
+	}, nil
+}
+
+// percentile returns the q-th quantile of the sorted latencies (nearest rank).
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	idx := int(q*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// randomInputs returns count random integers of exactly bits bits.
+func randomInputs(bits, count int) ([]*big.Int, error) {
+	if bits < 2 {
+		return nil, fmt.Errorf("bit length %d is too small", bits)
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	inputs := make([]*big.Int, count)
+	for i := range inputs {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return nil, err
+		}
+		inputs[i] = n.SetBit(n, bits-1, 1)
+	}
+	return inputs, nil
+}
+
+// parseInts parses a comma-separated list of positive integers.
+func parseInts(s string) ([]int, error) {
+	var res []int
+	for _, f := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		if v < 1 {
+			return nil, fmt.Errorf("value %d must be positive", v)
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// writers maps the names accepted by -format to report writers.
+var writers = map[string]func(io.Writer, Report) error{
+	"json": writeJSON,
+	"csv":  writeCSV,
+	"text": writeText,
+}
+
+// writeJSON writes the report as an indented JSON document.
+func writeJSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// csvHeader lists the CSV columns, matching the JSON field names of Result.
+var csvHeader = []string{
+	"algorithm", "bits", "routines", "iterations",
+	"mean_ns", "p50_ns", "p90_ns", "p99_ns", "max_ns",
+	"allocs_per_op", "bytes_per_op", "candidates_per_success",
+}
+
+// writeCSV writes one row per result, preceded by a header row.
+func writeCSV(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, res := range r.Results {
+		row := []string{
+			res.Algorithm,
+			strconv.Itoa(res.Bits),
+			strconv.Itoa(res.Routines),
+			strconv.Itoa(res.Iterations),
+			strconv.FormatInt(res.MeanNs, 10),
+			strconv.FormatInt(res.P50Ns, 10),
+			strconv.FormatInt(res.P90Ns, 10),
+			strconv.FormatInt(res.P99Ns, 10),
+			strconv.FormatInt(res.MaxNs, 10),
+			strconv.FormatUint(res.AllocsPerOp, 10),
+			strconv.FormatUint(res.BytesPerOp, 10),
+			strconv.FormatFloat(res.CandidatesPerSuccess, 'f', 2, 64),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeText writes a human-readable table.
+func writeText(w io.Writer, r Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "algorithm\tbits\troutines\titers\tmean\tp50\tp90\tp99\tmax\tallocs/op\tB/op\tcand/success\t\n")
+	for _, res := range r.Results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%v\t%v\t%v\t%v\t%v\t%d\t%d\t%.2f\t\n",
+			res.Algorithm, res.Bits, res.Routines, res.Iterations,
+			time.Duration(res.MeanNs), time.Duration(res.P50Ns), time.Duration(res.P90Ns),
+			time.Duration(res.P99Ns), time.Duration(res.MaxNs),
+			res.AllocsPerOp, res.BytesPerOp, res.CandidatesPerSuccess)
+	}
+	return tw.Flush()
+}
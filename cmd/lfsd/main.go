@@ -0,0 +1,63 @@
+// Command lfsd serves Lagrange four-square representations over HTTP with JSON
+// bodies, for services that cannot link the Go package.
+//
+// Endpoints:
+//
+//	POST /solve        {"n": "2029", "timeout_ms": 500} -> {"n": ..., "squares": [...], ...}
+//	POST /solve/batch  {"ns": ["5", "0x1f"], "timeout_ms": 500} -> {"squares": [[...], [...]], ...}
+//	POST /verify       {"n": "7", "squares": ["2", "1", "1", "1"]} -> {"valid": true}
+//	GET  /healthz      -> {"status": "ok"}
+//
+// Integers are decimal or 0x-prefixed hexadecimal strings. Errors are reported as
+// {"error": ...} with a 4xx or 5xx status: 413 for inputs above -max-bits, 503 if
+// no solve slot frees up before the deadline, and 504 if the solve misses it.
+//
+// Usage:
+//
+//	lfsd [-addr :8080] [-max-bits 8192] [-max-batch 256] [-concurrency 4] [-routines 4] [-timeout 10s] [-max-timeout 1m]
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/txaty/lfs"
+)
+
+func main() {
+	var (
+		addr        = flag.String("addr", ":8080", "listen address")
+		maxBits     = flag.Int("max-bits", 8192, "largest accepted bit length of an input")
+		maxBatch    = flag.Int("max-batch", 256, "largest number of inputs of a batch")
+		concurrency = flag.Int("concurrency", runtime.NumCPU(), "number of solves running at once")
+		routines    = flag.Int("routines", runtime.NumCPU(), "number of goroutines of each solve")
+		timeout     = flag.Duration("timeout", 10*time.Second, "deadline of a request without timeout_ms")
+		maxTimeout  = flag.Duration("max-timeout", time.Minute, "upper bound of timeout_ms")
+	)
+	flag.Parse()
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("lfsd: ")
+
+	cfg := config{
+		MaxBits:        *maxBits,
+		MaxBatch:       *maxBatch,
+		MaxConcurrency: *concurrency,
+		DefaultTimeout: *timeout,
+		MaxTimeout:     *maxTimeout,
+		// A decimal digit carries more than 3 bits; the rest is JSON syntax.
+		MaxBodyBytes: int64(*maxBatch)*int64(*maxBits/3+16) + 1<<10,
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           newServer(cfg, lfs.NewSolver(lfs.WithNumRoutines(*routines))),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("listening on %s", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
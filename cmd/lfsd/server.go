@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/txaty/lfs"
+)
+
+// config holds the limits of the service.
+type config struct {
+	MaxBits        int           // largest accepted bit length of an input
+	MaxBatch       int           // largest number of inputs of a batch
+	MaxConcurrency int           // number of solves running at once
+	DefaultTimeout time.Duration // deadline of a request without timeout_ms
+	MaxTimeout     time.Duration // upper bound of timeout_ms
+	MaxBodyBytes   int64         // largest accepted request body
+}
+
+// server serves the HTTP endpoints of lfsd.
+type server struct {
+	cfg    config
+	solver *lfs.Solver
+	sem    chan struct{} // one token per running solve
+	mux    *http.ServeMux
+}
+
+// newServer returns a server with the given limits that solves with solver.
+func newServer(cfg config, solver *lfs.Solver) *server {
+	s := &server{
+		cfg:    cfg,
+		solver: solver,
+		sem:    make(chan struct{}, max(1, cfg.MaxConcurrency)),
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /solve", s.handleSolve)
+	s.mux.HandleFunc("POST /solve/batch", s.handleSolveBatch)
+	s.mux.HandleFunc("POST /verify", s.handleVerify)
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Integer is an integer encoded in JSON as a decimal or 0x-prefixed
+// hexadecimal string, or as a plain number.
+type Integer struct {
+	big.Int
+}
+
+func (x *Integer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(x.String())
+}
+
+func (x *Integer) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	if len(s) > 2 && (s[:2] == "0x" || s[:2] == "0X") {
+		if _, ok := x.SetString(s[2:], 16); ok {
+			return nil
+		}
+	} else if _, ok := x.SetString(s, 10); ok {
+		return nil
+	}
+	return fmt.Errorf("invalid integer %q", s)
+}
+
+// SolveRequest is the body of POST /solve.
+type SolveRequest struct {
+	N         *Integer `json:"n"`
+	TimeoutMs int64    `json:"timeout_ms,omitempty"`
+}
+
+// SolveResponse is the body of a successful POST /solve.
+type SolveResponse struct {
+	N          *Integer    `json:"n"`
+	Squares    [4]*Integer `json:"squares"`
+	Candidates uint64      `json:"candidates"`
+	ElapsedNs  int64       `json:"elapsed_ns"`
+}
+
+// BatchRequest is the body of POST /solve/batch.
+type BatchRequest struct {
+	Ns        []*Integer `json:"ns"`
+	TimeoutMs int64      `json:"timeout_ms,omitempty"`
+}
+
+// BatchResponse is the body of a successful POST /solve/batch, with the
+// representations in the order of the inputs.
+type BatchResponse struct {
+	Squares   [][4]*Integer `json:"squares"`
+	ElapsedNs int64         `json:"elapsed_ns"`
+}
+
+// VerifyRequest is the body of POST /verify.
+type VerifyRequest struct {
+	N       *Integer    `json:"n"`
+	Squares [4]*Integer `json:"squares"`
+}
+
+// VerifyResponse is the body of a successful POST /verify.
+type VerifyResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// httpError is an error with the status code it is reported with.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+func errorf(status int, format string, args ...any) error {
+	return &httpError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+func (s *server) handleSolve(w http.ResponseWriter, r *http.Request) {
+	var req SolveRequest
+	if err := s.decode(w, r, &req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if err := s.checkInput(req.N, "n"); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	ctx, cancel, err := s.acquire(r.Context(), req.TimeoutMs)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	defer cancel()
+	start := time.Now()
+	fi, stats, err := s.solver.SolveWithStats(ctx, &req.N.Int)
+	if err != nil {
+		s.writeError(w, solveError(err))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, SolveResponse{
+		N:          req.N,
+		Squares:    toIntegers(fi),
+		Candidates: stats.Candidates,
+		ElapsedNs:  time.Since(start).Nanoseconds(),
+	})
+}
+
+func (s *server) handleSolveBatch(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	if err := s.decode(w, r, &req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if len(req.Ns) == 0 || len(req.Ns) > s.cfg.MaxBatch {
+		s.writeError(w, errorf(http.StatusBadRequest, "ns must hold 1 to %d inputs", s.cfg.MaxBatch))
+		return
+	}
+	ns := make([]*big.Int, len(req.Ns))
+	for i, n := range req.Ns {
+		if err := s.checkInput(n, fmt.Sprintf("ns[%d]", i)); err != nil {
+			s.writeError(w, err)
+			return
+		}
+		ns[i] = &n.Int
+	}
+	// A batch takes a single slot: SolveVector shares its workers across the inputs.
+	ctx, cancel, err := s.acquire(r.Context(), req.TimeoutMs)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	defer cancel()
+	start := time.Now()
+	results, err := s.solver.SolveVector(ctx, ns)
+	if err != nil {
+		s.writeError(w, solveError(err))
+		return
+	}
+	resp := BatchResponse{Squares: make([][4]*Integer, len(results))}
+	for i, fi := range results {
+		resp.Squares[i] = toIntegers(fi)
+	}
+	resp.ElapsedNs = time.Since(start).Nanoseconds()
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	var req VerifyRequest
+	if err := s.decode(w, r, &req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if err := s.checkInput(req.N, "n"); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	var fi lfs.FourInt
+	for i, x := range req.Squares {
+		if x == nil {
+			s.writeError(w, errorf(http.StatusBadRequest, "missing squares[%d]", i))
+			return
+		}
+		fi[i] = &x.Int
+	}
+	s.writeJSON(w, http.StatusOK, VerifyResponse{Valid: lfs.Verify(&req.N.Int, fi)})
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// decode decodes the JSON body of r into v, rejecting unknown fields and bodies
+// above the configured size.
+func (s *server) decode(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return errorf(http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", maxErr.Limit)
+		}
+		return errorf(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+// checkInput checks that the input named name is present, non-negative and within
+// the bit-length limit.
+func (s *server) checkInput(n *Integer, name string) error {
+	switch {
+	case n == nil:
+		return errorf(http.StatusBadRequest, "missing %s", name)
+	case n.Sign() < 0:
+		return errorf(http.StatusBadRequest, "%s must not be negative", name)
+	case n.BitLen() > s.cfg.MaxBits:
+		return errorf(http.StatusRequestEntityTooLarge, "%s has %d bits, the limit is %d", name, n.BitLen(), s.cfg.MaxBits)
+	}
+	return nil
+}
+
+// acquire derives the deadline of a request from its timeout_ms and takes a
+// concurrency slot, waiting at most until the deadline. The returned cancel
+// function releases the slot.
+func (s *server) acquire(ctx context.Context, timeoutMs int64) (context.Context, context.CancelFunc, error) {
+	if timeoutMs < 0 {
+		return nil, nil, errorf(http.StatusBadRequest, "timeout_ms must not be negative")
+	}
+	timeout := s.cfg.DefaultTimeout
+	if timeoutMs > 0 {
+		timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+	timeout = min(timeout, s.cfg.MaxTimeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	select {
+	case s.sem <- struct{}{}:
+		return ctx, func() { <-s.sem; cancel() }, nil
+	case <-ctx.Done():
+		cancel()
+		return nil, nil, errorf(http.StatusServiceUnavailable, "server busy: no solve slot within %v", timeout)
+	}
+}
+
+// solveError maps an error of the solver onto the status it is reported with.
+func solveError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, lfs.ErrSearchExhausted):
+		return errorf(http.StatusGatewayTimeout, "solve did not finish before the deadline")
+	case errors.Is(err, context.Canceled):
+		return errorf(http.StatusServiceUnavailable, "request cancelled")
+	}
+	return errorf(http.StatusInternalServerError, "%v", err)
+}
+
+func (s *server) writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he *httpError
+	if errors.As(err, &he) {
+		status = he.status
+	}
+	s.writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+func (s *server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// The status is sent; an encoding error can only be a failed write to the client.
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// toIntegers converts a representation into its JSON form.
+func toIntegers(fi lfs.FourInt) [4]*Integer {
+	var out [4]*Integer
+	for i, x := range fi {
+		out[i] = &Integer{}
+		out[i].Set(x)
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/txaty/lfs"
+)
+
+var testConfig = config{
+	MaxBits:        1024,
+	MaxBatch:       4,
+	MaxConcurrency: 2,
+	DefaultTimeout: 10 * time.Second,
+	MaxTimeout:     time.Minute,
+	MaxBodyBytes:   1 << 12,
+}
+
+// post sends body to path and decodes the response into v, returning the status.
+func post(t *testing.T, ts *httptest.Server, path, body string, v any) int {
+	t.Helper()
+	resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusMethodNotAllowed && ct != "application/json" {
+		t.Errorf("POST %s: Content-Type = %q", path, ct)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("POST %s: decoding response: %v", path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestSolve(t *testing.T) {
+	ts := httptest.NewServer(newServer(testConfig, lfs.NewSolver(lfs.WithNumRoutines(2))))
+	defer ts.Close()
+	tests := []struct {
+		name       string
+		body       string
+		n          *big.Int
+		wantStatus int
+	}{
+		{name: "small", body: `{"n": "2029"}`, n: big.NewInt(2029), wantStatus: http.StatusOK},
+		{name: "number", body: `{"n": 31}`, n: big.NewInt(31), wantStatus: http.StatusOK},
+		{name: "hex", body: `{"n": "0x` + strings.Repeat("f", 64) + `", "timeout_ms": 5000}`,
+			n: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)), wantStatus: http.StatusOK},
+		{name: "missing", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "negative", body: `{"n": "-5"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid", body: `{"n": "12a"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", body: `{"n": "5", "m": 1}`, wantStatus: http.StatusBadRequest},
+		{name: "negative timeout", body: `{"n": "5", "timeout_ms": -1}`, wantStatus: http.StatusBadRequest},
+		{name: "too many bits", body: `{"n": "0x1` + strings.Repeat("0", 256) + `"}`, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "body too large", body: `{"n": "` + strings.Repeat("1", 1<<12) + `"}`, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct {
+				SolveResponse
+				ErrorResponse
+			}
+			status := post(t, ts, "/solve", tt.body, &resp)
+			if status != tt.wantStatus {
+				t.Fatalf("POST /solve %s = %d (%s), want %d", tt.body, status, resp.Error, tt.wantStatus)
+			}
+			if status != http.StatusOK {
+				if resp.Error == "" {
+					t.Error("missing error message")
+				}
+				return
+			}
+			var fi lfs.FourInt
+			for i, x := range resp.Squares {
+				fi[i] = &x.Int
+			}
+			if !lfs.Verify(tt.n, fi) || resp.N.Cmp(tt.n) != 0 {
+				t.Errorf("POST /solve %s = %+v does not verify", tt.body, resp.SolveResponse)
+			}
+		})
+	}
+
+	if resp, err := http.Get(ts.URL + "/solve"); err != nil {
+		t.Fatal(err)
+	} else if resp.Body.Close(); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /solve = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestSolveLimits(t *testing.T) {
+	never := lfs.WithPrimalityTest(func(*big.Int) bool { return false })
+	cfg := testConfig
+	cfg.MaxConcurrency = 1
+	srv := newServer(cfg, lfs.NewSolver(never, lfs.WithNumRoutines(1)))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	n := `"0x` + strings.Repeat("f", 64) + `"`
+
+	// The search never succeeds and runs into the deadline of the request.
+	var resp ErrorResponse
+	if status := post(t, ts, "/solve", `{"n": `+n+`, "timeout_ms": 20}`, &resp); status != http.StatusGatewayTimeout {
+		t.Errorf("POST /solve = %d (%s), want %d", status, resp.Error, http.StatusGatewayTimeout)
+	}
+	// With the only slot taken, the request gives up at its deadline.
+	srv.sem <- struct{}{}
+	if status := post(t, ts, "/solve", `{"n": `+n+`, "timeout_ms": 20}`, &resp); status != http.StatusServiceUnavailable {
+		t.Errorf("POST /solve = %d (%s), want %d", status, resp.Error, http.StatusServiceUnavailable)
+	}
+	<-srv.sem
+}
+
+func TestSolveBatch(t *testing.T) {
+	ts := httptest.NewServer(newServer(testConfig, lfs.NewSolver(lfs.WithNumRoutines(2))))
+	defer ts.Close()
+	var resp BatchResponse
+	if status := post(t, ts, "/solve/batch", `{"ns": ["5", "0x1f", "2^8", "0"]}`, nil); status != http.StatusBadRequest {
+		t.Errorf("POST /solve/batch with an expression = %d, want %d", status, http.StatusBadRequest)
+	}
+	ns := []string{"5", "0x1f", "0", "340282366920938463463374607431768211507"}
+	body, _ := json.Marshal(map[string]any{"ns": ns})
+	if status := post(t, ts, "/solve/batch", string(body), &resp); status != http.StatusOK {
+		t.Fatalf("POST /solve/batch = %d", status)
+	}
+	if len(resp.Squares) != len(ns) {
+		t.Fatalf("POST /solve/batch returned %d results, want %d", len(resp.Squares), len(ns))
+	}
+	for i, sq := range resp.Squares {
+		var n Integer
+		if err := n.UnmarshalJSON([]byte(`"` + ns[i] + `"`)); err != nil {
+			t.Fatal(err)
+		}
+		var fi lfs.FourInt
+		for j, x := range sq {
+			fi[j] = &x.Int
+		}
+		if !lfs.Verify(&n.Int, fi) {
+			t.Errorf("result %d = %v does not verify for %s", i, fi, ns[i])
+		}
+	}
+	for _, body := range []string{`{"ns": []}`, `{"ns": ["1", "2", "3", "4", "5"]}`, `{"ns": ["1", null]}`} {
+		if status := post(t, ts, "/solve/batch", body, nil); status != http.StatusBadRequest {
+			t.Errorf("POST /solve/batch %s = %d, want %d", body, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestVerifyAndHealth(t *testing.T) {
+	ts := httptest.NewServer(newServer(testConfig, lfs.NewSolver()))
+	defer ts.Close()
+	tests := []struct {
+		body       string
+		wantStatus int
+		wantValid  bool
+	}{
+		{body: `{"n": "7", "squares": ["2", "1", "1", "1"]}`, wantStatus: http.StatusOK, wantValid: true},
+		{body: `{"n": "7", "squares": ["2", "1", "1", "0"]}`, wantStatus: http.StatusOK, wantValid: false},
+		{body: `{"n": "7", "squares": ["2", "1", "1"]}`, wantStatus: http.StatusBadRequest},
+		{body: `{"squares": ["2", "1", "1", "1"]}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		var resp VerifyResponse
+		if status := post(t, ts, "/verify", tt.body, &resp); status != tt.wantStatus || status == http.StatusOK && resp.Valid != tt.wantValid {
+			t.Errorf("POST /verify %s = %d %+v, want %d, valid %t", tt.body, status, resp, tt.wantStatus, tt.wantValid)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(buf.String(), `"ok"`) {
+		t.Errorf("GET /healthz = %d %s", resp.StatusCode, buf.String())
+	}
+}
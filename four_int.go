@@ -1,11 +1,28 @@
 package lfs
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math/big"
 	"sort"
 	"strings"
 )
 
+// fourIntBinaryVersion is the version byte that starts the binary encoding of FourInt.
+const fourIntBinaryVersion = 1
+
+var (
+	// ErrNilComponent is returned when encoding a FourInt with a nil component,
+	// such as the empty result of a failed solve.
+	ErrNilComponent = errors.New("lfs: FourInt has a nil component")
+	// ErrInvalidEncoding is returned when decoding a malformed FourInt.
+	ErrInvalidEncoding = errors.New("lfs: invalid FourInt encoding")
+	// ErrNotDivisible is returned by ExactDiv if a component is not divisible.
+	ErrNotDivisible = errors.New("lfs: component not divisible")
+)
+
 // FourInt represents a group of four big.Int values.
 type FourInt [4]*big.Int
 
@@ -30,13 +47,98 @@ func (f *FourInt) Mul(n *big.Int) {
 	}
 }
 
-// Div divides each component of FourInt by n.
+// Div divides each component of FourInt by n, truncating the quotients as
+// big.Int.Div does. Use ExactDiv to divide a representation of n*m^2 by m.
 func (f *FourInt) Div(n *big.Int) {
 	for i := range f {
 		f[i].Div(f[i], n)
 	}
 }
 
+// ExactDiv divides each component of FourInt by n if all of them are divisible by
+// n. Otherwise, it returns ErrNotDivisible and leaves f unchanged.
+func (f *FourInt) ExactDiv(n *big.Int) error {
+	if n.Sign() == 0 {
+		return fmt.Errorf("%w: division by zero", ErrNotDivisible)
+	}
+	var q FourInt
+	r := new(big.Int)
+	for i, x := range f {
+		if q[i], _ = new(big.Int).QuoRem(x, n, r); r.Sign() != 0 {
+			return fmt.Errorf("%w: %v by %v", ErrNotDivisible, x, n)
+		}
+	}
+	for i := range f {
+		f[i].Set(q[i])
+	}
+	return nil
+}
+
+// Norm returns the sum of the squares of the components, which is the integer
+// that f represents.
+func (f FourInt) Norm() *big.Int {
+	sum, sq := new(big.Int), new(big.Int)
+	for _, x := range f {
+		sum.Add(sum, sq.Mul(x, x))
+	}
+	return sum
+}
+
+// Equal reports whether f and o have equal components, in order. Nil components
+// are only equal to nil.
+func (f FourInt) Equal(o FourInt) bool {
+	for i := range f {
+		if (f[i] == nil) != (o[i] == nil) || f[i] != nil && f[i].Cmp(o[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Clone returns a copy of f that does not share components with it.
+func (f FourInt) Clone() FourInt {
+	var c FourInt
+	for i, x := range f {
+		if x != nil {
+			c[i] = new(big.Int).Set(x)
+		}
+	}
+	return c
+}
+
+// IsZero reports whether every component is nil or zero. This holds for the empty
+// result of a failed solve and for the representation of 0.
+func (f FourInt) IsZero() bool {
+	for _, x := range f {
+		if x != nil && x.Sign() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Format implements fmt.Formatter. It prints the components in the format of String,
+// each formatted with the verb and flags as big.Int does, so that %x prints them in
+// hexadecimal and %#x with a 0x prefix. %v and %s print them in decimal.
+func (f FourInt) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'b', 'o', 'O', 'd', 'x', 'X', 's', 'v':
+	default:
+		fmt.Fprintf(s, "%%!%c(lfs.FourInt=%s)", verb, f.String())
+		return
+	}
+	format := fmt.FormatString(s, verb)
+	for i, x := range f {
+		if i == 0 {
+			fmt.Fprint(s, "{")
+		} else {
+			fmt.Fprint(s, ", ")
+		}
+		fmt.Fprintf(s, format, x)
+	}
+	fmt.Fprint(s, "}")
+}
+
 // String returns a string representation of FourInt.
 func (f *FourInt) String() string {
 	parts := make([]string, len(f))
@@ -45,3 +147,160 @@ func (f *FourInt) String() string {
 	}
 	return "{" + strings.Join(parts, ", ") + "}"
 }
+
+// ParseFourInt parses a FourInt in the format of String, "{a, b, c, d}", with
+// decimal or 0x-prefixed hexadecimal components. Spaces around the components are
+// optional.
+func ParseFourInt(s string) (FourInt, error) {
+	inner, hasPrefix := strings.CutPrefix(strings.TrimSpace(s), "{")
+	inner, hasSuffix := strings.CutSuffix(inner, "}")
+	if !hasPrefix || !hasSuffix {
+		return FourInt{}, fmt.Errorf("%w: %q is not enclosed in braces", ErrInvalidEncoding, s)
+	}
+	parts := strings.Split(inner, ",")
+	if len(parts) != len(FourInt{}) {
+		return FourInt{}, fmt.Errorf("%w: %q has %d components, want 4", ErrInvalidEncoding, s, len(parts))
+	}
+	var f FourInt
+	for i, part := range parts {
+		x, err := parseComponent(strings.TrimSpace(part))
+		if err != nil {
+			return FourInt{}, err
+		}
+		f[i] = x
+	}
+	return f, nil
+}
+
+// parseComponent parses a decimal or 0x-prefixed hexadecimal integer.
+func parseComponent(s string) (*big.Int, error) {
+	digits, base := s, 10
+	if len(s) > 2 && (s[:2] == "0x" || s[:2] == "0X") {
+		digits, base = s[2:], 16
+	}
+	x, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid component %q", ErrInvalidEncoding, s)
+	}
+	return x, nil
+}
+
+// checkComponents returns ErrNilComponent if a component of f is nil.
+func (f FourInt) checkComponents() error {
+	for _, x := range f {
+		if x == nil {
+			return ErrNilComponent
+		}
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler in the format of String.
+func (f FourInt) MarshalText() ([]byte, error) {
+	if err := f.checkComponents(); err != nil {
+		return nil, err
+	}
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting the format of ParseFourInt.
+func (f *FourInt) UnmarshalText(text []byte) error {
+	parsed, err := ParseFourInt(string(text))
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The components are encoded as an array of
+// decimal strings, which keeps them exact in languages without big integers.
+func (f FourInt) MarshalJSON() ([]byte, error) {
+	if err := f.checkComponents(); err != nil {
+		return nil, err
+	}
+	var parts [4]string
+	for i, x := range f {
+		parts[i] = x.String()
+	}
+	return json.Marshal(parts)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts an array of four decimal or
+// 0x-prefixed hexadecimal strings, or of four JSON numbers. Like the types of
+// encoding/json, it leaves f unchanged for null.
+func (f *FourInt) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	if len(parts) != len(f) {
+		return fmt.Errorf("%w: %d components, want 4", ErrInvalidEncoding, len(parts))
+	}
+	var parsed FourInt
+	for i, part := range parts {
+		s := string(part)
+		if len(part) > 0 && part[0] == '"' {
+			if err := json.Unmarshal(part, &s); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+			}
+		}
+		x, err := parseComponent(s)
+		if err != nil {
+			return err
+		}
+		parsed[i] = x
+	}
+	*f = parsed
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The encoding is a version byte
+// followed by the four components, each as a uvarint byte length and its big-endian
+// magnitude. Components must not be negative.
+func (f FourInt) MarshalBinary() ([]byte, error) {
+	if err := f.checkComponents(); err != nil {
+		return nil, err
+	}
+	size := 1
+	for _, x := range f {
+		if x.Sign() < 0 {
+			return nil, fmt.Errorf("lfs: cannot encode negative component %v", x)
+		}
+		size += binary.MaxVarintLen64 + (x.BitLen()+7)/8
+	}
+	buf := make([]byte, 1, size)
+	buf[0] = fourIntBinaryVersion
+	for _, x := range f {
+		n := (x.BitLen() + 7) / 8
+		buf = binary.AppendUvarint(buf, uint64(n))
+		buf = buf[:len(buf)+n] // within the capacity reserved above
+		x.FillBytes(buf[len(buf)-n:])
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler for the encoding of MarshalBinary.
+func (f *FourInt) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != fourIntBinaryVersion {
+		return fmt.Errorf("%w: unsupported version", ErrInvalidEncoding)
+	}
+	data = data[1:]
+	var parsed FourInt
+	for i := range parsed {
+		n, k := binary.Uvarint(data)
+		if k <= 0 || n > uint64(len(data)-k) {
+			return fmt.Errorf("%w: truncated component %d", ErrInvalidEncoding, i)
+		}
+		parsed[i] = new(big.Int).SetBytes(data[k : k+int(n)])
+		data = data[k+int(n):]
+	}
+	if len(data) != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, len(data))
+	}
+	*f = parsed
+	return nil
+}
@@ -0,0 +1,206 @@
+package lfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// testFourInts returns representations of small and large values, including zeros.
+func testFourInts(t *testing.T) []FourInt {
+	t.Helper()
+	large := new(big.Int).Sub(new(big.Int).Lsh(big1, 1024), big.NewInt(189))
+	return []FourInt{
+		NewFourInt(big0, big0, big0, big0),
+		NewFourInt(big.NewInt(2), big1, big1, big1),
+		NewFourInt(big.NewInt(255), big.NewInt(256), big.NewInt(1<<62), big0),
+		NewSolver().Solve(large),
+	}
+}
+
+func TestFourIntMarshaling(t *testing.T) {
+	for _, f := range testFourInts(t) {
+		t.Run(fmt.Sprintf("%d bits", f[0].BitLen()), func(t *testing.T) {
+			text, err := f.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fromText FourInt
+			if err := fromText.UnmarshalText(text); err != nil || fromText.String() != f.String() {
+				t.Errorf("UnmarshalText(%s) = %v, %v", text, fromText.String(), err)
+			}
+
+			js, err := json.Marshal(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fromJSON FourInt
+			if err := json.Unmarshal(js, &fromJSON); err != nil || fromJSON.String() != f.String() {
+				t.Errorf("json.Unmarshal(%s) = %v, %v", js, fromJSON.String(), err)
+			}
+
+			bin, err := f.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fromBinary FourInt
+			if err := fromBinary.UnmarshalBinary(bin); err != nil || fromBinary.String() != f.String() {
+				t.Errorf("UnmarshalBinary(%x) = %v, %v", bin, fromBinary.String(), err)
+			}
+		})
+	}
+
+	// The zero FourInt returned by a failed solve cannot be encoded.
+	if _, err := json.Marshal(FourInt{}); !errors.Is(err, ErrNilComponent) {
+		t.Errorf("json.Marshal(FourInt{}) error = %v, want %v", err, ErrNilComponent)
+	}
+	if _, err := (FourInt{big.NewInt(-1), big0, big0, big0}).MarshalBinary(); err == nil {
+		t.Error("MarshalBinary() of a negative component succeeded")
+	}
+}
+
+func TestFourIntUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: `["3", "0x10", "1", "0"]`, want: "{3, 16, 1, 0}"},
+		{in: `[3, 2, 1, 0]`, want: "{3, 2, 1, 0}"},
+		{in: `["3", "2", "1"]`, wantErr: true},
+		{in: `["3", "2", "1", "x"]`, wantErr: true},
+		{in: `{"a": 1}`, wantErr: true},
+		{in: `[1.5, 2, 1, 0]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var f FourInt
+		err := json.Unmarshal([]byte(tt.in), &f)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("json.Unmarshal(%s) error = %v, wantErr %t", tt.in, err, tt.wantErr)
+		}
+		if !tt.wantErr && f.String() != tt.want {
+			t.Errorf("json.Unmarshal(%s) = %v, want %v", tt.in, f.String(), tt.want)
+		}
+	}
+
+	// null leaves the value unchanged, as for the types of encoding/json.
+	f := NewFourInt(big.NewInt(3), big.NewInt(2), big1, big0)
+	if err := json.Unmarshal([]byte("null"), &f); err != nil || f.String() != "{3, 2, 1, 0}" {
+		t.Errorf("json.Unmarshal(null) = %v, %v, want {3, 2, 1, 0} unchanged", f.String(), err)
+	}
+}
+
+func TestParseFourInt(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "{3, 2, 1, 0}", want: "{3, 2, 1, 0}"},
+		{in: " {3,2,1,0} ", want: "{3, 2, 1, 0}"},
+		{in: "{0xff, 2, 1, -4}", want: "{255, 2, 1, -4}"},
+		{in: "3, 2, 1, 0", wantErr: true},
+		{in: "3, 2, 1, 0}", wantErr: true},
+		{in: "{3, 2, 1, 0", wantErr: true},
+		{in: "{3, 2, 1}", wantErr: true},
+		{in: "{3, 2, 1, 0, 0}", wantErr: true},
+		{in: "{3, 2, , 0}", wantErr: true},
+		{in: "{}", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFourInt(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseFourInt(%q) error = %v, wantErr %t", tt.in, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidEncoding) {
+				t.Errorf("ParseFourInt(%q) error = %v, want %v", tt.in, err, ErrInvalidEncoding)
+			}
+		} else if got.String() != tt.want {
+			t.Errorf("ParseFourInt(%q) = %v, want %v", tt.in, got.String(), tt.want)
+		}
+	}
+}
+
+func TestFourIntUnmarshalBinary(t *testing.T) {
+	valid, err := NewFourInt(big.NewInt(300), big1, big1, big0).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "version", data: append([]byte{2}, valid[1:]...)},
+		{name: "truncated", data: valid[:len(valid)-2]},
+		{name: "trailing", data: append(append([]byte{}, valid...), 0)},
+		{name: "length overflow", data: []byte{1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+	}
+	for _, tt := range tests {
+		var f FourInt
+		if err := f.UnmarshalBinary(tt.data); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("%s: UnmarshalBinary(%x) error = %v, want %v", tt.name, tt.data, err, ErrInvalidEncoding)
+		}
+	}
+}
+
+func TestFourIntUtilities(t *testing.T) {
+	f := NewFourInt(big.NewInt(6), big.NewInt(-3), big.NewInt(9), big0)
+	if got := f.Norm(); got.Cmp(big.NewInt(126)) != 0 {
+		t.Errorf("Norm() = %v, want 126", got)
+	}
+	c := f.Clone()
+	if !c.Equal(f) || c[0] == f[0] {
+		t.Fatalf("Clone() = %v shares components or differs from %v", c.String(), f.String())
+	}
+	if c[3].SetInt64(1); c.Equal(f) {
+		t.Errorf("Equal(%v) = true after modifying the clone", f.String())
+	}
+	if (FourInt{}).Equal(f) || !(FourInt{}).Equal(FourInt{}) {
+		t.Error("Equal does not treat nil components as distinct from values")
+	}
+	if !(FourInt{}).IsZero() || !NewFourInt(big0, big0, big0, big0).IsZero() || f.IsZero() {
+		t.Error("IsZero reports wrong results")
+	}
+
+	if err := f.ExactDiv(big.NewInt(2)); !errors.Is(err, ErrNotDivisible) {
+		t.Errorf("ExactDiv(2) error = %v, want %v", err, ErrNotDivisible)
+	}
+	if f.String() != "{9, 6, 3, 0}" {
+		t.Errorf("ExactDiv(2) modified f to %v", f.String())
+	}
+	if err := f.ExactDiv(big0); !errors.Is(err, ErrNotDivisible) {
+		t.Errorf("ExactDiv(0) error = %v, want %v", err, ErrNotDivisible)
+	}
+	if err := f.ExactDiv(big.NewInt(-3)); err != nil || f.String() != "{-3, -2, -1, 0}" {
+		t.Errorf("ExactDiv(-3) = %v, %v", f.String(), err)
+	}
+}
+
+func TestFourIntFormat(t *testing.T) {
+	f := NewFourInt(big.NewInt(255), big.NewInt(16), big1, big0)
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "%v", want: "{255, 16, 1, 0}"},
+		{format: "%d", want: "{255, 16, 1, 0}"},
+		{format: "%s", want: "{255, 16, 1, 0}"},
+		{format: "%x", want: "{ff, 10, 1, 0}"},
+		{format: "%#X", want: "{0XFF, 0X10, 0X1, 0X0}"},
+		{format: "%b", want: "{11111111, 10000, 1, 0}"},
+		{format: "%3d", want: "{255,  16,   1,   0}"},
+		{format: "%q", want: "%!q(lfs.FourInt={255, 16, 1, 0})"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprintf(tt.format, f); got != tt.want {
+			t.Errorf("Sprintf(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+	if got := fmt.Sprintf("%v", FourInt{}); got != "{<nil>, <nil>, <nil>, <nil>}" {
+		t.Errorf("Sprintf(%%v) of the zero value = %q", got)
+	}
+}
@@ -0,0 +1,479 @@
+package lfs
+
+import (
+	"math"
+	"math/big"
+
+	comp "github.com/txaty/go-bigcomplex"
+)
+
+const (
+	// defaultFastGCDThreshold is the default bit length from which the Lehmer-style
+	// GCDs replace the plain Euclidean ones of go-bigcomplex.
+	defaultFastGCDThreshold = 256
+	// lehmerMinBits is the size below which the Lehmer-style GCDs fall back to
+	// exact Euclidean steps.
+	lehmerMinBits = 128
+	// lehmerApproxBits is the precision of the leading-part approximations.
+	lehmerApproxBits = 53
+	// lehmerStopNorm stops the approximate steps once the norm of the scaled
+	// remainder drops below it, as its leading bits are no longer reliable.
+	lehmerStopNorm = 1 << 54
+	// lehmerMaxEntry bounds the components of the cofactor matrix so that the
+	// int64 products in the approximate steps cannot overflow.
+	lehmerMaxEntry = 1 << 30
+)
+
+// useFastGCD reports whether the Lehmer-style GCDs should be used for operands of the size of x.
+func useFastGCD(x *big.Int, fastBits int) bool {
+	return fastBits > 0 && x.BitLen() >= fastBits
+}
+
+// gaussianGCDLehmer computes a greatest common divisor of the Gaussian integers
+// (ar + ai*i) and (br + bi*i) using a Lehmer-style Euclidean algorithm.
+//
+// Each round approximates both operands by their leading 53 bits, runs the
+// Euclidean algorithm on the approximations in machine precision while
+// accumulating the unimodular cofactor matrix, and applies the matrix to the
+// full-precision operands at once. As the matrix is unimodular, the GCD is
+// preserved regardless of the accuracy of the approximate quotients; a round
+// that fails to shrink the operands is replaced by one exact Euclidean step.
+// This replaces most multi-precision divisions with word-sized arithmetic.
+func gaussianGCDLehmer(ar, ai, br, bi *big.Int) *comp.GaussianInt {
+	a := [2]*big.Int{new(big.Int).Set(ar), new(big.Int).Set(ai)}
+	b := [2]*big.Int{new(big.Int).Set(br), new(big.Int).Set(bi)}
+	na, nb := gaussianNorm(a), gaussianNorm(b)
+	if na.Cmp(nb) < 0 {
+		a, b, na, nb = b, a, nb, na
+	}
+	newA := [2]*big.Int{new(big.Int), new(big.Int)}
+	newB := [2]*big.Int{new(big.Int), new(big.Int)}
+	opt := new(big.Int)
+	for nb.Sign() != 0 {
+		if top := maxBitLen(a[0], a[1], b[0], b[1]); top > lehmerMinBits {
+			shift := uint(top - lehmerApproxBits)
+			m, ok := gaussianLehmerMatrix(
+				approxFloat(a[0], shift, opt), approxFloat(a[1], shift, opt),
+				approxFloat(b[0], shift, opt), approxFloat(b[1], shift, opt),
+			)
+			if ok {
+				gaussianMulAdd(newA, m[0], a, m[1], b, opt)
+				gaussianMulAdd(newB, m[2], a, m[3], b, opt)
+				nNewA, nNewB := gaussianNorm(newA), gaussianNorm(newB)
+				if nNewA.Cmp(nNewB) < 0 {
+					newA, newB, nNewA, nNewB = newB, newA, nNewB, nNewA
+				}
+				if nNewA.BitLen() < na.BitLen() {
+					a, newA, b, newB = newA, a, newB, b
+					na, nb = nNewA, nNewB
+					continue
+				}
+			}
+		}
+		// Exact step: (a, b) <- (b, a - q*b).
+		gaussianRem(newB, a, b, nb, opt)
+		a, b, newB = b, newB, a
+		na, nb = nb, gaussianNorm(b)
+	}
+	return comp.NewGaussianInt(a[0], a[1])
+}
+
+// gaussianLehmerMatrix runs the Euclidean algorithm on the approximations
+// (ar + ai*i, br + bi*i) and returns the accumulated cofactor matrix
+// [m0 m1; m2 m3] with Gaussian entries {re, im}. It reports false if no step
+// could be taken.
+func gaussianLehmerMatrix(ar, ai, br, bi float64) ([4][2]int64, bool) {
+	m := [4][2]int64{{1, 0}, {0, 0}, {0, 0}, {1, 0}}
+	steps := 0
+	for {
+		nb := br*br + bi*bi
+		if nb < lehmerStopNorm {
+			break
+		}
+		// q = round(a * conj(b) / N(b))
+		qr := math.Round((ar*br + ai*bi) / nb)
+		qi := math.Round((ai*br - ar*bi) / nb)
+		// Rows: (m0, m1) <- (m2, m3), (m2, m3) <- (m0, m1) - q*(m2, m3).
+		var next [2][2]int64
+		overflow := false
+		for c := 0; c < 2; c++ {
+			er, ei := m[2+c][0], m[2+c][1]
+			nr := m[c][0] - (int64(qr)*er - int64(qi)*ei)
+			ni := m[c][1] - (int64(qr)*ei + int64(qi)*er)
+			if absInt64(nr) > lehmerMaxEntry || absInt64(ni) > lehmerMaxEntry {
+				overflow = true
+			}
+			next[c] = [2]int64{nr, ni}
+		}
+		if overflow || math.Abs(qr) > lehmerMaxEntry || math.Abs(qi) > lehmerMaxEntry {
+			break
+		}
+		m[0], m[1], m[2], m[3] = m[2], m[3], next[0], next[1]
+		ar, ai, br, bi = br, bi, ar-(qr*br-qi*bi), ai-(qr*bi+qi*br)
+		steps++
+	}
+	return m, steps > 0
+}
+
+// gaussianMulAdd sets dst = m0*x + m1*y for small Gaussian m0, m1.
+func gaussianMulAdd(dst [2]*big.Int, m0 [2]int64, x [2]*big.Int, m1 [2]int64, y [2]*big.Int, opt *big.Int) {
+	dst[0].SetInt64(0)
+	dst[1].SetInt64(0)
+	for _, term := range [2]struct {
+		m [2]int64
+		v [2]*big.Int
+	}{{m0, x}, {m1, y}} {
+		// (mr + mi*i)(vr + vi*i) = (mr*vr - mi*vi) + (mr*vi + mi*vr)*i
+		dst[0].Add(dst[0], opt.Mul(opt.SetInt64(term.m[0]), term.v[0]))
+		dst[0].Sub(dst[0], opt.Mul(opt.SetInt64(term.m[1]), term.v[1]))
+		dst[1].Add(dst[1], opt.Mul(opt.SetInt64(term.m[0]), term.v[1]))
+		dst[1].Add(dst[1], opt.Mul(opt.SetInt64(term.m[1]), term.v[0]))
+	}
+}
+
+// gaussianRem sets r = a - q*b with q = round(a * conj(b) / N(b)), where nb = N(b).
+func gaussianRem(r, a, b [2]*big.Int, nb, opt *big.Int) {
+	qr := new(big.Int).Mul(a[0], b[0])
+	qr.Add(qr, opt.Mul(a[1], b[1]))
+	roundDiv(qr, qr, nb)
+	qi := new(big.Int).Mul(a[1], b[0])
+	qi.Sub(qi, opt.Mul(a[0], b[1]))
+	roundDiv(qi, qi, nb)
+	r[0].Mul(qr, b[0])
+	r[0].Sub(r[0], opt.Mul(qi, b[1]))
+	r[0].Sub(a[0], r[0])
+	r[1].Mul(qr, b[1])
+	r[1].Add(r[1], opt.Mul(qi, b[0]))
+	r[1].Sub(a[1], r[1])
+}
+
+// gaussianNorm returns x[0]^2 + x[1]^2.
+func gaussianNorm(x [2]*big.Int) *big.Int {
+	n := new(big.Int).Mul(x[0], x[0])
+	return n.Add(n, new(big.Int).Mul(x[1], x[1]))
+}
+
+// hurwitzGCRDLehmer computes a greatest common right-divisor of the Hurwitz
+// integers a and b, given by their doubled components, using the same
+// Lehmer-style scheme as gaussianGCDLehmer with quaternion cofactors multiplied
+// from the left. Quotients are rounded to the nearest Hurwitz integer, which
+// guarantees that every exact step shrinks the norm. The result is returned as
+// an associate with integer components.
+func hurwitzGCRDLehmer(a, b [4]*big.Int) *comp.HurwitzInt {
+	a = [4]*big.Int{new(big.Int).Set(a[0]), new(big.Int).Set(a[1]), new(big.Int).Set(a[2]), new(big.Int).Set(a[3])}
+	b = [4]*big.Int{new(big.Int).Set(b[0]), new(big.Int).Set(b[1]), new(big.Int).Set(b[2]), new(big.Int).Set(b[3])}
+	na, nb := quatNorm(a), quatNorm(b)
+	if na.Cmp(nb) < 0 {
+		a, b, na, nb = b, a, nb, na
+	}
+	newA := newQuat()
+	newB := newQuat()
+	opt := new(big.Int)
+	for nb.Sign() != 0 {
+		if top := maxBitLen(append(a[:], b[:]...)...); top > lehmerMinBits {
+			shift := uint(top - lehmerApproxBits)
+			var fa, fb [4]float64
+			for c := 0; c < 4; c++ {
+				fa[c] = approxFloat(a[c], shift, opt)
+				fb[c] = approxFloat(b[c], shift, opt)
+			}
+			m, ok := hurwitzLehmerMatrix(fa, fb)
+			if ok {
+				quatMulAdd(newA, m[0], a, m[1], b, opt)
+				quatMulAdd(newB, m[2], a, m[3], b, opt)
+				nNewA, nNewB := quatNorm(newA), quatNorm(newB)
+				if nNewA.Cmp(nNewB) < 0 {
+					newA, newB, nNewA, nNewB = newB, newA, nNewB, nNewA
+				}
+				if nNewA.BitLen() < na.BitLen() {
+					a, newA, b, newB = newA, a, newB, b
+					na, nb = nNewA, nNewB
+					continue
+				}
+			}
+		}
+		// Exact step: (a, b) <- (b, a - q*b).
+		hurwitzRem(newB, a, b, nb)
+		a, b, newB = b, newB, a
+		na, nb = nb, quatNorm(b)
+	}
+	return lipschitzAssociate(a)
+}
+
+// hurwitzLehmerMatrix runs the right Euclidean algorithm on the doubled
+// approximations a and b and returns the accumulated cofactor matrix
+// [m0 m1; m2 m3] with doubled Hurwitz entries, acting by left multiplication.
+// It reports false if no step could be taken.
+func hurwitzLehmerMatrix(a, b [4]float64) ([4][4]int64, bool) {
+	m := [4][4]int64{{2, 0, 0, 0}, {}, {}, {2, 0, 0, 0}}
+	steps := 0
+	for {
+		nb := b[0]*b[0] + b[1]*b[1] + b[2]*b[2] + b[3]*b[3]
+		if nb < lehmerStopNorm {
+			break
+		}
+		// x = a * conj(b) / N(b); the doubled components cancel out.
+		x := quatMulFloat(a, [4]float64{b[0], -b[1], -b[2], -b[3]})
+		for c := range x {
+			x[c] /= nb
+		}
+		q := roundHurwitz(x)
+		if absInt64(q[0]) > lehmerMaxEntry || absInt64(q[1]) > lehmerMaxEntry ||
+			absInt64(q[2]) > lehmerMaxEntry || absInt64(q[3]) > lehmerMaxEntry {
+			break
+		}
+		// Rows: (m0, m1) <- (m2, m3), (m2, m3) <- (m0, m1) - q*(m2, m3).
+		var next [2][4]int64
+		overflow := false
+		for c := 0; c < 2; c++ {
+			prod := quatMulInt64(q, m[2+c])
+			for d := 0; d < 4; d++ {
+				// Both factors are doubled, so the product is halved.
+				next[c][d] = m[c][d] - prod[d]/2
+				if absInt64(next[c][d]) > lehmerMaxEntry {
+					overflow = true
+				}
+			}
+		}
+		if overflow {
+			break
+		}
+		m[0], m[1], m[2], m[3] = m[2], m[3], next[0], next[1]
+		qb := quatMulFloat([4]float64{float64(q[0]) / 2, float64(q[1]) / 2, float64(q[2]) / 2, float64(q[3]) / 2}, b)
+		a, b = b, [4]float64{a[0] - qb[0], a[1] - qb[1], a[2] - qb[2], a[3] - qb[3]}
+		steps++
+	}
+	return m, steps > 0
+}
+
+// quatMulAdd sets dst = (m0*x + m1*y) / 2 for doubled small m0, m1 and doubled x, y,
+// so that dst is doubled as well.
+func quatMulAdd(dst [4]*big.Int, m0 [4]int64, x [4]*big.Int, m1 [4]int64, y [4]*big.Int, opt *big.Int) {
+	for c := 0; c < 4; c++ {
+		dst[c].SetInt64(0)
+	}
+	for _, term := range [2]struct {
+		m [4]int64
+		v [4]*big.Int
+	}{{m0, x}, {m1, y}} {
+		for c := 0; c < 4; c++ {
+			for d := 0; d < 4; d++ {
+				sign, idx := quatProdTable[c][d][0], quatProdTable[c][d][1]
+				opt.Mul(opt.SetInt64(term.m[c]), term.v[d])
+				if sign > 0 {
+					dst[idx].Add(dst[idx], opt)
+				} else {
+					dst[idx].Sub(dst[idx], opt)
+				}
+			}
+		}
+	}
+	for c := 0; c < 4; c++ {
+		dst[c].Rsh(dst[c], 1)
+	}
+}
+
+// hurwitzRem sets r = a - q*b for doubled a, b, where q is the Hurwitz integer
+// nearest to a * conj(b) / N(b) and nb is the norm of the doubled b.
+func hurwitzRem(r, a, b [4]*big.Int, nb *big.Int) {
+	bConj := [4]*big.Int{b[0], new(big.Int).Neg(b[1]), new(big.Int).Neg(b[2]), new(big.Int).Neg(b[3])}
+	num := quatMul(a, bConj)
+	// The doubled quotient 2x = 2 * num / nb is rounded to the nearest vector
+	// with components of equal parity.
+	var even, odd [4]*big.Int
+	var errEven, errOdd big.Int
+	twoNum := new(big.Int)
+	for c := 0; c < 4; c++ {
+		twoNum.Lsh(num[c], 1)
+		// Nearest even integer to 2x: 2 * round(x).
+		even[c] = roundDiv(new(big.Int), num[c], nb)
+		even[c].Lsh(even[c], 1)
+		// Nearest odd integer to 2x: 2 * floor(x) + 1.
+		odd[c] = new(big.Int).Div(num[c], nb)
+		odd[c].Lsh(odd[c], 1).Add(odd[c], big1)
+		errEven.Add(&errEven, sqDist(twoNum, even[c], nb))
+		errOdd.Add(&errOdd, sqDist(twoNum, odd[c], nb))
+	}
+	q := even
+	if errOdd.Cmp(&errEven) < 0 {
+		q = odd
+	}
+	qb := quatMul(q, b)
+	for c := 0; c < 4; c++ {
+		r[c].Rsh(qb[c], 1)
+		r[c].Sub(a[c], r[c])
+	}
+}
+
+// sqDist returns (twoNum - v*nb)^2, the scaled squared distance between the
+// doubled quotient twoNum/nb and v.
+func sqDist(twoNum, v, nb *big.Int) *big.Int {
+	d := new(big.Int).Mul(v, nb)
+	d.Sub(twoNum, d)
+	return d.Mul(d, d)
+}
+
+// lipschitzAssociate returns the left associate of the doubled Hurwitz integer h
+// with integer components, which exists for every Hurwitz integer.
+func lipschitzAssociate(h [4]*big.Int) *comp.HurwitzInt {
+	if h[0].Bit(0) == 0 {
+		return comp.NewHurwitzInt(h[0], h[1], h[2], h[3], true)
+	}
+	// Half-integer components: multiply by a unit (1 ± i ± j ± k)/2 from the left.
+	for signs := 0; signs < 8; signs++ {
+		u := [4]*big.Int{big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1)}
+		for c := 1; c < 4; c++ {
+			if signs&(1<<(c-1)) != 0 {
+				u[c].Neg(u[c])
+			}
+		}
+		// u holds the doubled unit, so the doubled product is u*h/2.
+		prod := quatMul(u, h)
+		for c := range prod {
+			prod[c].Rsh(prod[c], 1)
+		}
+		if prod[0].Bit(0) == 0 && prod[1].Bit(0) == 0 && prod[2].Bit(0) == 0 && prod[3].Bit(0) == 0 {
+			return comp.NewHurwitzInt(prod[0], prod[1], prod[2], prod[3], true)
+		}
+	}
+	// Unreachable: one of the units always yields integer components.
+	return comp.NewHurwitzInt(h[0], h[1], h[2], h[3], true)
+}
+
+// quatProdTable describes the Hamilton product: the term x[c]*y[d] contributes
+// with sign quatProdTable[c][d][0] to component quatProdTable[c][d][1].
+var quatProdTable = [4][4][2]int{
+	{{1, 0}, {1, 1}, {1, 2}, {1, 3}},
+	{{1, 1}, {-1, 0}, {1, 3}, {-1, 2}},
+	{{1, 2}, {-1, 3}, {-1, 0}, {1, 1}},
+	{{1, 3}, {1, 2}, {-1, 1}, {-1, 0}},
+}
+
+// quatMul returns the Hamilton product x*y.
+func quatMul(x, y [4]*big.Int) [4]*big.Int {
+	res := newQuat()
+	opt := new(big.Int)
+	for c := 0; c < 4; c++ {
+		for d := 0; d < 4; d++ {
+			sign, idx := quatProdTable[c][d][0], quatProdTable[c][d][1]
+			opt.Mul(x[c], y[d])
+			if sign > 0 {
+				res[idx].Add(res[idx], opt)
+			} else {
+				res[idx].Sub(res[idx], opt)
+			}
+		}
+	}
+	return res
+}
+
+// quatMulInt64 returns the Hamilton product x*y of small quaternions.
+func quatMulInt64(x, y [4]int64) [4]int64 {
+	var res [4]int64
+	for c := 0; c < 4; c++ {
+		for d := 0; d < 4; d++ {
+			res[quatProdTable[c][d][1]] += int64(quatProdTable[c][d][0]) * x[c] * y[d]
+		}
+	}
+	return res
+}
+
+// quatMulFloat returns the Hamilton product x*y of approximate quaternions.
+func quatMulFloat(x, y [4]float64) [4]float64 {
+	var res [4]float64
+	for c := 0; c < 4; c++ {
+		for d := 0; d < 4; d++ {
+			res[quatProdTable[c][d][1]] += float64(quatProdTable[c][d][0]) * x[c] * y[d]
+		}
+	}
+	return res
+}
+
+// roundHurwitz returns the doubled components of the Hurwitz integer nearest to x.
+func roundHurwitz(x [4]float64) [4]int64 {
+	var even, odd [4]int64
+	var errEven, errOdd float64
+	for c := 0; c < 4; c++ {
+		e := math.Round(x[c])
+		o := math.Floor(x[c]) + 0.5
+		errEven += (x[c] - e) * (x[c] - e)
+		errOdd += (x[c] - o) * (x[c] - o)
+		even[c] = 2 * int64(e)
+		odd[c] = int64(2 * o)
+	}
+	if errOdd < errEven {
+		return odd
+	}
+	return even
+}
+
+// quatNorm returns the sum of the squares of the components.
+func quatNorm(x [4]*big.Int) *big.Int {
+	n := new(big.Int)
+	opt := new(big.Int)
+	for c := 0; c < 4; c++ {
+		n.Add(n, opt.Mul(x[c], x[c]))
+	}
+	return n
+}
+
+// newQuat returns a zero quaternion with allocated components.
+func newQuat() [4]*big.Int {
+	return [4]*big.Int{new(big.Int), new(big.Int), new(big.Int), new(big.Int)}
+}
+
+// roundDiv sets z to x/y rounded to the nearest integer, for y > 0, and returns z.
+func roundDiv(z, x, y *big.Int) *big.Int {
+	// floor((2x + y) / 2y)
+	num := new(big.Int).Lsh(x, 1)
+	num.Add(num, y)
+	den := new(big.Int).Lsh(y, 1)
+	return z.Div(num, den)
+}
+
+// approxFloat returns x / 2^shift as a float64, using opt as scratch space.
+func approxFloat(x *big.Int, shift uint, opt *big.Int) float64 {
+	return float64(opt.Rsh(x, shift).Int64())
+}
+
+// maxBitLen returns the largest bit length among xs.
+func maxBitLen(xs ...*big.Int) int {
+	m := 0
+	for _, x := range xs {
+		if bl := x.BitLen(); bl > m {
+			m = bl
+		}
+	}
+	return m
+}
+
+// absInt64 returns the absolute value of x.
+func absInt64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// cornacchiaSplit returns a + b*i with a^2 + b^2 = p for a prime p = 1 (mod 4),
+// given s with s^2 = -1 (mod p). It runs the Euclidean algorithm on (p, s) and
+// stops at the first remainder a below sqrt(p), as in Cornacchia's algorithm,
+// using only integer arithmetic. It reports false if p - a^2 is not a square,
+// which can only happen if s is not a square root of -1 modulo p.
+func cornacchiaSplit(s, p *big.Int) (*comp.GaussianInt, bool) {
+	limit := new(big.Int).Sqrt(p)
+	r0 := new(big.Int).Set(p)
+	r1 := new(big.Int).Mod(s, p)
+	rem := new(big.Int)
+	for r1.Cmp(limit) > 0 {
+		rem.Rem(r0, r1)
+		r0, r1, rem = r1, rem, r0
+	}
+	b := rem.Mul(r1, r1)
+	b.Sub(p, b)
+	b.Sqrt(b)
+	if check := new(big.Int).Mul(b, b); check.Add(check, r0.Mul(r1, r1)).Cmp(p) != 0 {
+		return nil, false
+	}
+	return comp.NewGaussianInt(r1, b), true
+}
@@ -0,0 +1,239 @@
+package lfs
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	comp "github.com/txaty/go-bigcomplex"
+	"lukechampine.com/frand"
+)
+
+// gcdTests lists the operand sizes of the Lehmer-style GCD tests. The Euclidean
+// algorithms of go-bigcomplex lose precision on large random operands, so they
+// only serve as a reference up to 1024 bits.
+var gcdTests = []struct {
+	bits       int
+	crossCheck bool
+}{
+	{bits: 64, crossCheck: true},
+	{bits: 512, crossCheck: true},
+	{bits: 1024, crossCheck: true},
+	{bits: 4096, crossCheck: false},
+}
+
+func TestGaussianGCDLehmer(t *testing.T) {
+	for _, tt := range gcdTests {
+		bits := tt.bits
+		t.Run(fmt.Sprintf("%d bits", bits), func(t *testing.T) {
+			for _, p := range testPrimesOneModFour(t, min(bits, 1024), 2) {
+				s, ok := computeSqrtMinusOne(p)
+				if !ok {
+					t.Fatalf("computeSqrtMinusOne(%v) failed", p)
+				}
+				got := gaussianGCDLehmer(s, big1, p, big0)
+				if got.Norm().Cmp(p) != 0 {
+					t.Fatalf("gaussianGCDLehmer(%v+i, %v) = %v, norm != p", s, p, got)
+				}
+			}
+			// Random operands with a common factor, cross-checked against go-bigcomplex.
+			for i := 0; i < 4; i++ {
+				g := randGaussianInt(bits / 2)
+				a := new(comp.GaussianInt).Prod(g, randGaussianInt(bits/2))
+				b := new(comp.GaussianInt).Prod(g, randGaussianInt(bits/2))
+				got := gaussianGCDLehmer(a.R, a.I, b.R, b.I)
+				if tt.crossCheck {
+					want := new(comp.GaussianInt).GCD(a.Copy(), b.Copy())
+					if got.Norm().Cmp(want.Norm()) != 0 {
+						t.Fatalf("gaussianGCDLehmer(%v, %v) = %v, want an associate of %v", a, b, got, want)
+					}
+				} else if new(big.Int).Mod(got.Norm(), g.Norm()).Sign() != 0 {
+					t.Fatalf("gaussianGCDLehmer(%v, %v) = %v is not a multiple of %v", a, b, got, g)
+				}
+				for _, x := range []*comp.GaussianInt{a, b} {
+					if !gaussianDivides(got, x) {
+						t.Fatalf("gaussianGCDLehmer(%v, %v) = %v does not divide %v", a, b, got, x)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHurwitzGCRDLehmer(t *testing.T) {
+	solver := NewSolver()
+	for _, tt := range gcdTests {
+		bits := tt.bits
+		t.Run(fmt.Sprintf("%d bits", bits), func(t *testing.T) {
+			// Random operands with a common right factor. The GCRD of go-bigcomplex
+			// rounds quotients to Lipschitz integers, which does not terminate on
+			// general operands, so only the divisibility properties are checked.
+			for i := 0; i < 4; i++ {
+				d := randLipschitzInt(bits / 2)
+				a := new(comp.HurwitzInt).Prod(randLipschitzInt(bits/2), d)
+				b := new(comp.HurwitzInt).Prod(randLipschitzInt(bits/2), d)
+				got := hurwitzGCRDLehmer(doubledComponents(a), doubledComponents(b))
+				gr, gi, gj, gk := got.ValInt()
+				if got.Norm().Cmp(comp.NewHurwitzInt(gr, gi, gj, gk, false).Norm()) != 0 {
+					t.Fatalf("hurwitzGCRDLehmer(%v, %v) = %v has half-integer components", a, b, got)
+				}
+				if !rightDivides(doubledComponents(d), doubledComponents(got)) {
+					t.Fatalf("hurwitzGCRDLehmer(%v, %v) = %v is not a right multiple of %v", a, b, got, d)
+				}
+				for _, x := range []*comp.HurwitzInt{a, b} {
+					if !rightDivides(doubledComponents(got), doubledComponents(x)) {
+						t.Fatalf("hurwitzGCRDLehmer(%v, %v) = %v does not right-divide %v", a, b, got, x)
+					}
+				}
+			}
+			if !tt.crossCheck {
+				return
+			}
+			// The operands of the solver, cross-checked against go-bigcomplex.
+			n := new(big.Int).Lsh(big1, uint(bits-1))
+			n.Add(n, frand.BigIntn(n)).SetBit(n, 0, 1)
+			res, _, err := solver.findGaussianGCDLarge(context.Background(), n, n.BitLen(), solver.NumRoutines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fcmRes, _, err := solver.fcmRandTrail(context.Background(), n, solver.NumRoutines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gcd, fcmGCD, l := res.gcd, fcmRes.gcd, fcmRes.l
+			for _, gcrd := range []struct {
+				name      string
+				got, want *comp.HurwitzInt
+			}{
+				{"basic", finalizeHurwitzGCRD(n, gcd, 1), finalizeHurwitzGCRD(n, gcd, 0)},
+				{"fcm", fcmFinalizeHurwitzGCRD(n, l, fcmGCD, 1), fcmFinalizeHurwitzGCRD(n, l, fcmGCD, 0)},
+			} {
+				if gcrd.got.Norm().Cmp(n) != 0 || gcrd.want.Norm().Cmp(n) != 0 {
+					t.Fatalf("%s: hurwitzGCRDLehmer = %v, want an associate of %v with norm %v", gcrd.name, gcrd.got, gcrd.want, n)
+				}
+			}
+		})
+	}
+}
+
+func TestCornacchiaSplit(t *testing.T) {
+	for _, bits := range []int{16, 128, 1024} {
+		for _, p := range testPrimesOneModFour(t, bits, 4) {
+			s, ok := computeSqrtMinusOne(p)
+			if !ok {
+				t.Fatalf("computeSqrtMinusOne(%v) failed", p)
+			}
+			got, ok := cornacchiaSplit(s, p)
+			if !ok || got.Norm().Cmp(p) != 0 {
+				t.Fatalf("cornacchiaSplit(%v, %v) = %v, %t, want a norm of p", s, p, got, ok)
+			}
+			// The split does not depend on the choice of the square root.
+			got, ok = cornacchiaSplit(new(big.Int).Sub(p, s), p)
+			if !ok || got.Norm().Cmp(p) != 0 {
+				t.Fatalf("cornacchiaSplit(-%v, %v) = %v, %t, want a norm of p", s, p, got, ok)
+			}
+		}
+	}
+	// 4^2 = 3 (mod 13) is not a square root of -1.
+	if got, ok := cornacchiaSplit(big.NewInt(4), big.NewInt(13)); ok {
+		t.Errorf("cornacchiaSplit(4, 13) = %v, want failure", got)
+	}
+}
+
+func BenchmarkGaussianGCD(b *testing.B) {
+	for _, bits := range []int{128, 512, 2048, 8192} {
+		x, y := randGaussianInt(bits), randGaussianInt(bits)
+		b.Run(fmt.Sprintf("bigcomplex/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				new(comp.GaussianInt).GCD(x.Copy(), y.Copy())
+			}
+		})
+		b.Run(fmt.Sprintf("lehmer/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				gaussianGCDLehmer(x.R, x.I, y.R, y.I)
+			}
+		})
+	}
+}
+
+func BenchmarkHurwitzGCRD(b *testing.B) {
+	solver := NewSolver()
+	for _, bits := range []int{128, 512, 2048} {
+		n := new(big.Int).Lsh(big1, uint(bits-1))
+		n.Add(n, frand.BigIntn(n)).SetBit(n, 0, 1)
+		res, _, err := solver.findGaussianGCDLarge(context.Background(), n, n.BitLen(), solver.NumRoutines)
+		if err != nil {
+			b.Fatal(err)
+		}
+		gcd := res.gcd
+		b.Run(fmt.Sprintf("bigcomplex/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				finalizeHurwitzGCRD(n, gcd, 0)
+			}
+		})
+		b.Run(fmt.Sprintf("lehmer/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				finalizeHurwitzGCRD(n, gcd, 1)
+			}
+		})
+	}
+}
+
+// randGaussianInt returns a random Gaussian integer with components below 2^bits.
+func randGaussianInt(bits int) *comp.GaussianInt {
+	limit := new(big.Int).Lsh(big1, uint(bits))
+	return comp.NewGaussianInt(frand.BigIntn(limit), frand.BigIntn(limit))
+}
+
+// randLipschitzInt returns a random Lipschitz integer with components below 2^bits.
+func randLipschitzInt(bits int) *comp.HurwitzInt {
+	limit := new(big.Int).Lsh(big1, uint(bits))
+	return comp.NewHurwitzInt(frand.BigIntn(limit), frand.BigIntn(limit), frand.BigIntn(limit), frand.BigIntn(limit), false)
+}
+
+// doubledComponents returns the doubled components of a Lipschitz integer.
+func doubledComponents(h *comp.HurwitzInt) [4]*big.Int {
+	r, i, j, k := h.ValInt()
+	return [4]*big.Int{r.Lsh(r, 1), i.Lsh(i, 1), j.Lsh(j, 1), k.Lsh(k, 1)}
+}
+
+// gaussianDivides reports whether d divides x by checking that x*conj(d)/N(d) is integral.
+func gaussianDivides(d, x *comp.GaussianInt) bool {
+	q := new(comp.GaussianInt).Prod(x, new(comp.GaussianInt).Conj(d))
+	nd := d.Norm()
+	return new(big.Int).Mod(q.R, nd).Sign() == 0 && new(big.Int).Mod(q.I, nd).Sign() == 0
+}
+
+// rightDivides reports whether x = q*d for a Hurwitz integer q, given the doubled
+// components of d and x, by checking that the doubled q = 2*x*conj(d)/N(d) is integral
+// with components of equal parity.
+func rightDivides(d, x [4]*big.Int) bool {
+	q := quatMul(x, [4]*big.Int{d[0], new(big.Int).Neg(d[1]), new(big.Int).Neg(d[2]), new(big.Int).Neg(d[3])})
+	nd := quatNorm(d)
+	rem := new(big.Int)
+	for c := range q {
+		q[c].Lsh(q[c], 1)
+		if q[c].QuoRem(q[c], nd, rem); rem.Sign() != 0 {
+			return false
+		}
+	}
+	return q[0].Bit(0) == q[1].Bit(0) && q[1].Bit(0) == q[2].Bit(0) && q[2].Bit(0) == q[3].Bit(0)
+}
+
+func BenchmarkSplit(b *testing.B) {
+	for _, bits := range []int{128, 256, 1024} {
+		p := testPrimesOneModFour(b, bits, 1)[0]
+		s, _ := computeSqrtMinusOne(p)
+		b.Run(fmt.Sprintf("gcd/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				computeGaussianGCD(s, p, 1)
+			}
+		})
+		b.Run(fmt.Sprintf("cornacchia/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cornacchiaSplit(s, p)
+			}
+		})
+	}
+}
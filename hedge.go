@@ -0,0 +1,60 @@
+package lfs
+
+import (
+	"context"
+	"math/big"
+)
+
+// hedges reports whether n is close enough to the FCM threshold to race the basic
+// algorithm against the FCM algorithm. Racing needs a worker for each algorithm,
+// so with fewer than two routines n is solved by a single algorithm.
+func (s *Solver) hedges(n *big.Int) bool {
+	if s.HedgingMargin <= 0 || s.NumRoutines < 2 {
+		return false
+	}
+	diff := n.BitLen() - s.FCMThreshold.BitLen()
+	return -s.HedgingMargin <= diff && diff <= s.HedgingMargin
+}
+
+// hedgedResult is the outcome of one of the algorithms raced by solveHedged.
+type hedgedResult struct {
+	fi    FourInt
+	stats Stats
+	err   error
+}
+
+// solveHedged runs the basic algorithm and the FCM algorithm concurrently, with
+// the at least two workers split between them, and returns the first
+// representation found. The other algorithm is cancelled. The returned stats
+// cover both searches.
+func (s *Solver) solveHedged(ctx context.Context, n *big.Int) (FourInt, Stats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	basicRoutines := (s.NumRoutines + 1) / 2
+	fcmRoutines := s.NumRoutines - basicRoutines
+	results := make(chan hedgedResult, 2)
+	go func() {
+		fi, stats, err := s.solveBasic(ctx, n, basicRoutines)
+		results <- hedgedResult{fi: fi, stats: stats, err: err}
+	}()
+	go func() {
+		fi, stats, err := s.solveFCM(ctx, n, fcmRoutines)
+		results <- hedgedResult{fi: fi, stats: stats, err: err}
+	}()
+	first := <-results
+	if first.err == nil {
+		cancel()
+	}
+	// Wait for the other algorithm, so that its workers have stopped and its
+	// counters are included in the stats.
+	second := <-results
+	stats := first.stats.merge(second.stats)
+	switch {
+	case first.err == nil:
+		return first.fi, stats, nil
+	case second.err == nil:
+		return second.fi, stats, nil
+	default:
+		return FourInt{}, stats, first.err
+	}
+}
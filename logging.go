@@ -0,0 +1,62 @@
+package lfs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// discardLogger drops all records. It is used when no logger is configured, so
+// that the solver is silent by default.
+var discardLogger = slog.New(discardHandler{})
+
+// discardHandler is a slog.Handler that is disabled at every level.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logger returns the configured logger, or discardLogger if there is none.
+func (s *Solver) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return discardLogger
+}
+
+// logSearch records the end of the search sp with its outcome err.
+func (s *Solver) logSearch(sp *searchParams, err error) {
+	if !sp.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	st := sp.search.snapshot()
+	attrs := []any{
+		slog.Int("bits", sp.preP.BitLen()),
+		slog.Duration("duration", time.Since(sp.start)),
+		slog.Uint64("candidates", st.Candidates),
+		slog.Int("routines", st.Routines),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	sp.logger.Debug("lfs: search finished", attrs...)
+}
+
+// logStart records the start of the worker.
+func (w *searchWorker) logStart() {
+	w.sp.logger.Debug("lfs: worker started", slog.Int("bits", w.sp.preP.BitLen()))
+}
+
+// logStop records the end of the worker with the number of candidates it examined.
+func (w *searchWorker) logStop() {
+	w.sp.logger.Debug("lfs: worker stopped", slog.Uint64("candidates", w.examined))
+}
+
+// logCompositeCandidate records a candidate that passed the primality test but
+// turned out to be composite. This is expected with a probabilistic test such as
+// FermatTest and only costs a retry, so it is a debug record.
+func (w *searchWorker) logCompositeCandidate(err error) {
+	w.sp.logger.Debug("lfs: composite candidate retried", slog.Int("bits", w.sp.preP.BitLen()), slog.Any("error", err))
+}
@@ -0,0 +1,101 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer that is safe for the concurrent writes of workers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWithLogger(t *testing.T) {
+	var out syncBuffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	n := new(big.Int).Sub(new(big.Int).Lsh(big1, 256), big.NewInt(189))
+
+	s := NewSolver(WithLogger(logger), WithNumRoutines(2), WithResultCache(1))
+	if got := s.Solve(n); !Verify(n, got) {
+		t.Fatalf("Solve() = %v does not verify", got)
+	}
+	// An exhausted search waits for its workers, so that their records are complete.
+	// Without primes, the search always runs out of attempts.
+	s = NewSolver(
+		WithLogger(logger),
+		WithNumRoutines(2),
+		WithMaxAttempts(1),
+		WithPrimalityTest(func(*big.Int) bool { return false }),
+	)
+	if _, _, err := s.SolveWithStats(context.Background(), n); !errors.Is(err, ErrSearchExhausted) {
+		t.Fatalf("SolveWithStats() error = %v, want %v", err, ErrSearchExhausted)
+	}
+	logs := out.String()
+	for _, want := range []string{
+		`"msg":"lfs: path selected","bits":256,"path":"basic"`,
+		`"msg":"lfs: worker started"`,
+		`"msg":"lfs: worker stopped"`,
+		`"msg":"lfs: search finished"`,
+		`"routines":2,"error":"lfs: search budget exhausted"`,
+		`"msg":"lfs: result cached","bits":256,"entries":1,"evicted":0`,
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs do not contain %s:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, `"level":"ERROR"`) {
+		t.Errorf("logs contain errors:\n%s", logs)
+	}
+}
+
+func TestLogCompositeCandidate(t *testing.T) {
+	var out syncBuffer
+	n := new(big.Int).Sub(new(big.Int).Lsh(big1, 256), big.NewInt(189))
+	// Accepting every candidate forces composites through the search, as in
+	// TestWithPrimalityTest, and each of them is logged as a debug record.
+	s := NewSolver(
+		WithLogger(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		WithPrimalityTest(func(*big.Int) bool { return true }),
+		WithNumRoutines(1),
+		WithSieveBound(0),
+		WithSeed(1),
+	)
+	if got := s.Solve(n); !Verify(n, got) {
+		t.Fatalf("Solve() = %v does not verify", got)
+	}
+	logs := out.String()
+	if got, want := uint64(strings.Count(logs, `level=DEBUG msg="lfs: composite candidate retried"`)), s.Stats().CompositeRetries; got == 0 || got != want {
+		t.Errorf("logged %d composite candidates for %d composite retries", got, want)
+	}
+	if strings.Contains(logs, "level=ERROR") {
+		t.Errorf("logs contain errors:\n%s", logs)
+	}
+	if !strings.Contains(logs, errNoSqrtMinusOne.Error()) {
+		t.Errorf("logs do not contain %q:\n%s", errNoSqrtMinusOne, logs)
+	}
+
+	// Without a logger, the solver is silent.
+	s = NewSolver()
+	if s.logger().Enabled(context.Background(), slog.LevelError) {
+		t.Error("the default logger is enabled")
+	}
+}
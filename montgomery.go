@@ -0,0 +1,362 @@
+//go:build math_big_pure_go || wasm
+
+package lfs
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// montWindowBits is the width of the fixed exponent windows of montContext.expMont.
+// Four bits need 16 table entries and one multiplication per window, which is the
+// best trade-off for exponents of 512 to 4096 bits.
+const montWindowBits = 4
+
+// montContext holds the Montgomery form of an odd modulus m with R = 2^(W*n) for
+// n words of W bits. It computes the square root of -1 of a candidate prime, one
+// exponentiation and one squaring, without the multi-precision division with which
+// big.Int.Exp computes R^2 mod m. It is only built for math_big_pure_go and wasm,
+// see sqrtMinusOne. The buffers are kept across init calls, so a context reused
+// for moduli of similar size does not allocate.
+type montContext struct {
+	n     int
+	m     []big.Word // modulus, least significant word first
+	k0    big.Word   // -m^-1 mod 2^W
+	one   []big.Word // R mod m, the Montgomery form of 1
+	rr    []big.Word // R^2 mod m, which converts values into Montgomery form
+	t     []big.Word // product accumulator of n+1 words
+	t2    []big.Word // square accumulator of 2n words
+	x     []big.Word // scratch operand
+	acc   []big.Word // exponentiation accumulator
+	table [1 << montWindowBits][]big.Word
+	out   *big.Int // result of exp
+}
+
+// init sets the context to the odd modulus m > 1.
+func (c *montContext) init(m *big.Int) {
+	mw := m.Bits()
+	c.n = len(mw)
+	c.m = growWords(c.m, c.n)
+	copy(c.m, mw)
+	// Newton's iteration doubles the number of correct low bits of m^-1 per step.
+	inv := c.m[0]
+	for i := 0; i < 6; i++ {
+		inv *= 2 - c.m[0]*inv
+	}
+	c.k0 = -inv
+	c.t = growWords(c.t, c.n+1)
+	c.t2 = growWords(c.t2, 2*c.n)
+	c.x = growWords(c.x, c.n)
+	c.acc = growWords(c.acc, c.n)
+	for i := range c.table {
+		c.table[i] = growWords(c.table[i], c.n)
+	}
+	if c.out == nil {
+		c.out = new(big.Int)
+	}
+
+	// R mod m by doubling 2^(bitLen-1) < m up to 2^(W*n).
+	c.one = growWords(c.one, c.n)
+	clear(c.one)
+	bitLen := m.BitLen()
+	c.one[(bitLen-1)/bits.UintSize] = 1 << ((bitLen - 1) % bits.UintSize)
+	for i := bitLen - 1; i < c.n*bits.UintSize; i++ {
+		c.double(c.one)
+	}
+	// Write W*n = j*2^e with j odd. Doubling R mod m j times gives 2^j*R, the
+	// Montgomery form of 2^j, and e Montgomery squarings give the Montgomery
+	// form of 2^(W*n), which is R^2 mod m.
+	c.rr = growWords(c.rr, c.n)
+	copy(c.rr, c.one)
+	e := bits.TrailingZeros(uint(c.n * bits.UintSize))
+	for i := 0; i < c.n*bits.UintSize>>e; i++ {
+		c.double(c.rr)
+	}
+	for i := 0; i < e; i++ {
+		c.sqr(c.rr, c.rr)
+	}
+}
+
+// double sets z = 2z mod m for z < m.
+func (c *montContext) double(z []big.Word) {
+	var carry big.Word
+	for i := range z {
+		hi := z[i] >> (bits.UintSize - 1)
+		z[i] = z[i]<<1 | carry
+		carry = hi
+	}
+	if carry != 0 || !lessWords(z, c.m) {
+		subWords(z, c.m)
+	}
+}
+
+// mul sets z = x*y/R mod m for x, y < m. It interleaves the multiplication and
+// the reduction word by word, so the accumulator stays at n+1 words. z may alias
+// x or y.
+func (c *montContext) mul(z, x, y []big.Word) {
+	n := c.n
+	m, t := c.m[:n], c.t[:n+1]
+	x, y = x[:n], y[:n]
+	clear(t)
+	for i := 0; i < n; i++ {
+		yi := uint(y[i])
+		// The first word fixes q such that t + x*y[i] + q*m is divisible by 2^W.
+		hi1, lo1 := bits.Mul(uint(x[0]), yi)
+		lo1, cc := bits.Add(lo1, uint(t[0]), 0)
+		c1 := hi1 + cc
+		q := lo1 * uint(c.k0)
+		hi2, lo2 := bits.Mul(uint(m[0]), q)
+		_, cc = bits.Add(lo2, lo1, 0)
+		c2 := hi2 + cc
+		for j := 1; j < n; j++ {
+			hi1, lo1 = bits.Mul(uint(x[j]), yi)
+			lo1, cc = bits.Add(lo1, uint(t[j]), 0)
+			hi1 += cc
+			lo1, cc = bits.Add(lo1, c1, 0)
+			c1 = hi1 + cc
+			hi2, lo2 = bits.Mul(uint(m[j]), q)
+			lo2, cc = bits.Add(lo2, lo1, 0)
+			hi2 += cc
+			lo2, cc = bits.Add(lo2, c2, 0)
+			c2 = hi2 + cc
+			t[j-1] = big.Word(lo2)
+		}
+		sum, cc1 := bits.Add(uint(t[n]), c1, 0)
+		sum, cc2 := bits.Add(sum, c2, 0)
+		t[n-1], t[n] = big.Word(sum), big.Word(cc1+cc2)
+	}
+	copy(z, t[:n])
+	if t[n] != 0 || !lessWords(z[:n], m) {
+		subWords(z[:n], m)
+	}
+}
+
+// sqr sets z = x*x/R mod m for x < m. The cross products x[i]*x[j] with i < j are
+// computed once and doubled, which saves almost half the word multiplications of
+// mul, before the double-width square is reduced. z may alias x.
+func (c *montContext) sqr(z, x []big.Word) {
+	n := c.n
+	m, t := c.m[:n], c.t2[:2*n]
+	x = x[:n]
+	clear(t)
+	for i := 0; i < n-1; i++ {
+		xi := uint(x[i])
+		var carry uint
+		for j := i + 1; j < n; j++ {
+			hi, lo := bits.Mul(uint(x[j]), xi)
+			lo, cc := bits.Add(lo, uint(t[i+j]), 0)
+			hi += cc
+			lo, cc = bits.Add(lo, carry, 0)
+			t[i+j], carry = big.Word(lo), hi+cc
+		}
+		t[i+n] = big.Word(carry)
+	}
+	var top big.Word
+	for i := range t {
+		top, t[i] = t[i]>>(bits.UintSize-1), t[i]<<1|top
+	}
+	var carry uint
+	for i := 0; i < n; i++ {
+		hi, lo := bits.Mul(uint(x[i]), uint(x[i]))
+		var cc uint
+		lo, cc = bits.Add(uint(t[2*i]), lo, carry)
+		hi, carry = bits.Add(uint(t[2*i+1]), hi, cc)
+		t[2*i], t[2*i+1] = big.Word(lo), big.Word(hi)
+	}
+
+	// Reduce t by adding multiples q*m*2^(W*i) that clear the low words. The
+	// overflow of each row is deferred to the word the next row ends in.
+	var over uint
+	for i := 0; i < n; i++ {
+		q := uint(t[i] * c.k0)
+		carry = 0
+		for j := 0; j < n; j++ {
+			hi, lo := bits.Mul(uint(m[j]), q)
+			lo, cc := bits.Add(lo, uint(t[i+j]), 0)
+			hi += cc
+			lo, cc = bits.Add(lo, carry, 0)
+			t[i+j], carry = big.Word(lo), hi+cc
+		}
+		var sum uint
+		sum, over = bits.Add(uint(t[i+n]), carry, over)
+		t[i+n] = big.Word(sum)
+	}
+	copy(z, t[n:])
+	if over != 0 || !lessWords(z[:n], m) {
+		subWords(z[:n], m)
+	}
+}
+
+// mulWord sets z = x*y mod m for x < m and a single word y. It costs a single
+// word-by-multi-word division step, against n Montgomery reduction steps for mul,
+// which makes exponentiations with small bases cheaper. As it does not divide by
+// R, it applies to Montgomery forms as well.
+func (c *montContext) mulWord(z, x []big.Word, y big.Word) {
+	n := c.n
+	m, t := c.m[:n], c.t[:n+1]
+	x = x[:n]
+	var carry uint
+	for j := 0; j < n; j++ {
+		hi, lo := bits.Mul(uint(x[j]), uint(y))
+		lo, cc := bits.Add(lo, carry, 0)
+		t[j], carry = big.Word(lo), hi+cc
+	}
+	t[n] = big.Word(carry)
+
+	// Estimate the quotient t/m < 2^W from the leading two words of t and the
+	// leading word of m, both shifted such that m has its top bit set. As in
+	// Knuth's algorithm D, the estimate exceeds the quotient by at most two.
+	shift := uint(bits.LeadingZeros(uint(m[n-1])))
+	mTop, tHi, tLo := uint(m[n-1])<<shift, uint(t[n])<<shift, uint(t[n-1])<<shift
+	if shift > 0 {
+		tHi |= uint(t[n-1]) >> (bits.UintSize - shift)
+		if n > 1 {
+			mTop |= uint(m[n-2]) >> (bits.UintSize - shift)
+			tLo |= uint(t[n-2]) >> (bits.UintSize - shift)
+		}
+	}
+	q := ^uint(0)
+	if tHi < mTop {
+		q, _ = bits.Div(tHi, tLo, mTop)
+	}
+	// t -= q*m, adding m back while the difference is negative.
+	var borrow, mc uint
+	for j := 0; j < n; j++ {
+		hi, lo := bits.Mul(uint(m[j]), q)
+		lo, cc := bits.Add(lo, mc, 0)
+		mc = hi + cc
+		var d uint
+		d, borrow = bits.Sub(uint(t[j]), lo, borrow)
+		t[j] = big.Word(d)
+	}
+	top, _ := bits.Sub(uint(t[n]), mc, borrow)
+	for int(top) < 0 {
+		var cc uint
+		for j := 0; j < n; j++ {
+			var sum uint
+			sum, cc = bits.Add(uint(t[j]), uint(m[j]), cc)
+			t[j] = big.Word(sum)
+		}
+		top += cc
+	}
+	for top != 0 || !lessWords(t[:n], m) {
+		var bb uint
+		for j := 0; j < n; j++ {
+			var d uint
+			d, bb = bits.Sub(uint(t[j]), uint(m[j]), bb)
+			t[j] = big.Word(d)
+		}
+		top -= bb
+	}
+	copy(z, t[:n])
+}
+
+// exp returns x^e mod m for 0 <= x < m. The result is owned by the context and
+// valid until its next use.
+func (c *montContext) exp(x, e *big.Int) *big.Int {
+	c.expMont(x, e)
+	return c.fromMont(c.acc)
+}
+
+// expMont sets the accumulator of the context to the Montgomery form of x^e mod m
+// for 0 <= x < m, using fixed windows of montWindowBits bits. Bases of a single
+// word are left to expWord.
+func (c *montContext) expMont(x, e *big.Int) {
+	if xw := x.Bits(); len(xw) <= 1 {
+		var w big.Word
+		if len(xw) == 1 {
+			w = xw[0]
+		}
+		c.expWord(w, e)
+		return
+	}
+	copy(c.acc, c.one)
+	c.toMont(c.table[1], x)
+	copy(c.table[0], c.one)
+	for i := 2; i < len(c.table); i++ {
+		c.mul(c.table[i], c.table[i-1], c.table[1])
+	}
+	for i := (e.BitLen() + montWindowBits - 1) / montWindowBits * montWindowBits; i > 0; i -= montWindowBits {
+		var d uint
+		for b := 1; b <= montWindowBits; b++ {
+			c.sqr(c.acc, c.acc)
+			d = d<<1 | e.Bit(i-b)
+		}
+		if d != 0 {
+			c.mul(c.acc, c.acc, c.table[d])
+		}
+	}
+}
+
+// expWord sets the accumulator of the context to the Montgomery form of x^e mod m
+// for a single word x < m, such as the small quadratic non-residues of the solver.
+// Multiplying by x with mulWord is cheap enough that windows do not pay off.
+func (c *montContext) expWord(x big.Word, e *big.Int) {
+	copy(c.acc, c.one)
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		c.sqr(c.acc, c.acc)
+		if e.Bit(i) != 0 {
+			c.mulWord(c.acc, c.acc, x)
+		}
+	}
+}
+
+// isMinusOne reports whether the Montgomery form z represents -1 mod m.
+func (c *montContext) isMinusOne(z []big.Word) bool {
+	// -R mod m = m - (R mod m), compared without materializing it.
+	var borrow uint
+	for i := 0; i < c.n; i++ {
+		var d uint
+		d, borrow = bits.Sub(uint(c.m[i]), uint(c.one[i]), borrow)
+		if big.Word(d) != z[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// toMont sets z to the Montgomery form x*R mod m of 0 <= x < m.
+func (c *montContext) toMont(z []big.Word, x *big.Int) {
+	clear(c.x)
+	copy(c.x, x.Bits())
+	c.mul(z, c.x, c.rr)
+}
+
+// fromMont returns the value of the Montgomery form z in the result of the context.
+func (c *montContext) fromMont(z []big.Word) *big.Int {
+	clear(c.x)
+	c.x[0] = 1
+	c.mul(c.x, z, c.x)
+	buf := c.out.Bits()
+	buf = growWords(buf[:0], c.n)
+	copy(buf, c.x)
+	return c.out.SetBits(buf)
+}
+
+// growWords returns a slice of n words, reusing the capacity of z.
+func growWords(z []big.Word, n int) []big.Word {
+	if cap(z) >= n {
+		return z[:n]
+	}
+	return make([]big.Word, n)
+}
+
+// lessWords reports whether x < y for slices of equal length.
+func lessWords(x, y []big.Word) bool {
+	for i := len(x) - 1; i >= 0; i-- {
+		if x[i] != y[i] {
+			return x[i] < y[i]
+		}
+	}
+	return false
+}
+
+// subWords sets z = z - y modulo 2^(W*len(z)).
+func subWords(z, y []big.Word) {
+	var borrow uint
+	for i := range z {
+		var d uint
+		d, borrow = bits.Sub(uint(z[i]), uint(y[i]), borrow)
+		z[i] = big.Word(d)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !math_big_pure_go && !wasm
+
+package lfs
+
+import "math/big"
+
+// workerMont is empty, as the candidate loop does not use montContext where
+// math/big has assembly kernels.
+type workerMont struct{}
+
+// sqrtMinusOne computes s with s^2 = -1 (mod p) with computeSqrtMinusOne. Where
+// math/big has assembly kernels, big.Int.Exp is about twice as fast as the portable
+// Montgomery multiplication of montContext from 512 to 4096 bits, so montContext
+// is only built for math_big_pure_go and wasm.
+func (w *searchWorker) sqrtMinusOne(p *big.Int) (*big.Int, bool) {
+	return computeSqrtMinusOne(p)
+}
@@ -0,0 +1,34 @@
+//go:build math_big_pure_go || wasm
+
+package lfs
+
+import "math/big"
+
+// workerMont is the Montgomery context of the current candidate prime of a worker.
+type workerMont = montContext
+
+// sqrtMinusOne computes s with s^2 = -1 (mod p) like computeSqrtMinusOne, in the
+// Montgomery context of the worker. Without the assembly kernels of math/big, the
+// context with its dedicated squaring is 15 to 35% faster than big.Int.Exp from
+// 512 to 4096 bits.
+func (w *searchWorker) sqrtMinusOne(p *big.Int) (*big.Int, bool) {
+	return w.montSqrtMinusOne(p)
+}
+
+// montSqrtMinusOne computes s = u^((p-1)/4) mod p in the Montgomery context of the
+// worker, which is built once for p and also checks s^2 = -1 (mod p) without a
+// multi-precision division.
+func (w *searchWorker) montSqrtMinusOne(p *big.Int) (*big.Int, bool) {
+	u := findQuadraticNonResidue(p)
+	if u == 0 {
+		return nil, false
+	}
+	c := &w.mont
+	c.init(p)
+	c.expWord(big.Word(u), w.opt.Rsh(p, 2)) // (p-1)/4 as p = 1 (mod 4)
+	c.sqr(c.x, c.acc)
+	if !c.isMinusOne(c.x) {
+		return nil, false
+	}
+	return new(big.Int).Set(c.fromMont(c.acc)), true
+}
@@ -0,0 +1,90 @@
+//go:build math_big_pure_go || wasm
+
+package lfs
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"lukechampine.com/frand"
+)
+
+// randOddModulus returns a random odd modulus of exactly bits bits.
+func randOddModulus(bits int) *big.Int {
+	m := frand.BigIntn(new(big.Int).Lsh(big1, uint(bits)))
+	return m.SetBit(m, bits-1, 1).SetBit(m, 0, 1)
+}
+
+func TestMontContext(t *testing.T) {
+	// A single context is reused across sizes to exercise the buffer reuse.
+	var c montContext
+	for _, bits := range []int{2, 63, 64, 65, 127, 512, 1024, 4096} {
+		t.Run(fmt.Sprintf("%d bits", bits), func(t *testing.T) {
+			for i := 0; i < 8; i++ {
+				m := randOddModulus(bits)
+				e := frand.BigIntn(m)
+				c.init(m)
+				for _, x := range []*big.Int{
+					big.NewInt(0),
+					new(big.Int).Mod(big.NewInt(3), m),
+					new(big.Int).Mod(new(big.Int).SetUint64(frand.Uint64n(1<<63)), m),
+					frand.BigIntn(m),
+				} {
+					want := new(big.Int).Exp(x, e, m)
+					if got := c.exp(x, e); got.Cmp(want) != 0 {
+						t.Fatalf("exp(%v, %v) mod %v = %v, want %v", x, e, m, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMontSqrtMinusOne(t *testing.T) {
+	w := newSearchWorker(nil)
+	for _, p := range testPrimesOneModFour(t, 512, 4) {
+		want, _ := computeSqrtMinusOne(p)
+		got, ok := w.montSqrtMinusOne(p)
+		if !ok || got.Cmp(want) != 0 {
+			t.Fatalf("montSqrtMinusOne(%v) = %v, %t, want %v", p, got, ok, want)
+		}
+	}
+	// 65 = 5*13 is composite, and with the non-residue 3, 3^16 = 16 does not square to -1 (mod 65).
+	if got, ok := w.montSqrtMinusOne(big.NewInt(65)); ok {
+		t.Errorf("montSqrtMinusOne(65) = %v, want failure", got)
+	}
+	// Building the context for another p of the same size does not allocate.
+	p := testPrimesOneModFour(t, 1024, 1)[0]
+	e := new(big.Int).Rsh(p, 2)
+	if allocs := testing.AllocsPerRun(10, func() {
+		w.mont.init(p)
+		w.mont.expWord(2, e)
+	}); allocs != 0 {
+		t.Errorf("init and expWord allocate %v times, want 0", allocs)
+	}
+}
+
+// BenchmarkExp compares big.Int.Exp with montContext for the exponentiation of
+// sqrtMinusOne. Like montContext, it is only built with the math_big_pure_go tag
+// or on wasm, as big.Int.Exp with assembly kernels is faster and always used.
+func BenchmarkExp(b *testing.B) {
+	for _, bits := range []int{512, 1024, 2048, 4096} {
+		p := randOddModulus(bits)
+		e := new(big.Int).Rsh(p, 2)
+		u := big.NewInt(3)
+		b.Run(fmt.Sprintf("big/%d", bits), func(b *testing.B) {
+			z := new(big.Int)
+			for i := 0; i < b.N; i++ {
+				z.Exp(u, e, p)
+			}
+		})
+		b.Run(fmt.Sprintf("montgomery/%d", bits), func(b *testing.B) {
+			var c montContext
+			for i := 0; i < b.N; i++ {
+				c.init(p)
+				c.exp(u, e)
+			}
+		})
+	}
+}
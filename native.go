@@ -0,0 +1,277 @@
+package lfs
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// nativeTwoSquaresLimit is the bound below which the native search splits any
+// remainder into two squares by trial, instead of requiring a prime p = 1 (mod 4).
+// Inputs below it are therefore searched exhaustively.
+const nativeTwoSquaresLimit = 1 << 16
+
+// millerRabinBases32 and millerRabinBases64 are sets of Miller-Rabin bases that
+// are deterministic for all odd integers below 2^32 and 2^64, respectively.
+var (
+	millerRabinBases32 = []uint64{2, 7, 61}
+	millerRabinBases64 = []uint64{2, 325, 9375, 28178, 450775, 9780504, 1795265022}
+)
+
+// SolveUint64 returns the four-square representation of n, with the components in
+// descending order. It uses native arithmetic and, apart from the fallback described
+// in SolveUint128, does not allocate.
+func SolveUint64(n uint64) [4]uint64 {
+	return SolveUint128(0, n)
+}
+
+// SolveUint128 returns the four-square representation of n = hi*2^64 + lo, with the
+// components in descending order. As n < 2^128, every component fits in 64 bits.
+// It uses native arithmetic and does not allocate, unless the native search fails.
+// It then falls back to SolveBasic of a default Solver, which allocates, starts
+// goroutines and ignores the configuration of any other Solver. The fallback has
+// not been observed in practice.
+//
+// After removing the factors of 4 from n, it walks x downward from sqrt(n) and y
+// downward from sqrt(n - x^2), so that the remainder m = n - x^2 - y^2 starts out
+// small and grows slowly. The first m that is either small enough to be split into
+// c^2 + d^2 by trial, or a prime p = 1 (mod 4) split by Cornacchia's algorithm,
+// completes the representation. Primes are tested with deterministic Miller-Rabin
+// bases, and only remainders below 2^64 are considered.
+func SolveUint128(hi, lo uint64) [4]uint64 {
+	if hi == 0 && lo == 0 {
+		return [4]uint64{}
+	}
+	// n = 4^e * n' with n' != 0 (mod 4), and the representation of n is 2^e times
+	// that of n'.
+	var e uint
+	if lo == 0 {
+		e = uint(64+bits.TrailingZeros64(hi)) / 2
+	} else {
+		e = uint(bits.TrailingZeros64(lo)) / 2
+	}
+	hi, lo = rsh128(hi, lo, 2*e)
+	w, ok := solveNative(hi, lo)
+	if !ok {
+		// Not observed in practice: fall back to the randomized search.
+		n := new(big.Int).SetUint64(hi)
+		n.Lsh(n, 64).Or(n, new(big.Int).SetUint64(lo))
+		fi := NewSolver().SolveBasic(n)
+		for i := range w {
+			w[i] = fi[i].Uint64()
+		}
+	}
+	for i := range w {
+		w[i] <<= e
+	}
+	sortDescending(&w)
+	return w
+}
+
+// solveNativeBig solves n < 2^128 with SolveUint128 and returns the representation
+// as a FourInt.
+func solveNativeBig(n *big.Int) FourInt {
+	var hi, lo uint64
+	if words := n.Bits(); bits.UintSize == 64 {
+		for i, w := range words {
+			if i == 0 {
+				lo = uint64(w)
+			} else {
+				hi = uint64(w)
+			}
+		}
+	} else {
+		for i, w := range words {
+			if i < 2 {
+				lo |= uint64(w) << (32 * i)
+			} else {
+				hi |= uint64(w) << (32 * (i - 2))
+			}
+		}
+	}
+	w := SolveUint128(hi, lo)
+	var fi FourInt
+	for i := range fi {
+		fi[i] = new(big.Int).SetUint64(w[i])
+	}
+	return fi
+}
+
+// solveNative searches the representation of n = hi*2^64 + lo as described in
+// SolveUint128. It reports false if no remainder below 2^64 could be split.
+func solveNative(hi, lo uint64) ([4]uint64, bool) {
+	for x := isqrt128(hi, lo); ; x-- {
+		xh, xl := bits.Mul64(x, x)
+		rl, borrow := bits.Sub64(lo, xl, 0)
+		rh, _ := bits.Sub64(hi, xh, borrow)
+		// As y^2 = 0 or 1 (mod 4), a remainder m = 1 (mod 4) needs r = 1 or 2 (mod 4).
+		primes := rl&3 == 1 || rl&3 == 2
+		for y := isqrt128(rh, rl); ; y-- {
+			yh, yl := bits.Mul64(y, y)
+			ml, borrow := bits.Sub64(rl, yl, 0)
+			mh, _ := bits.Sub64(rh, yh, borrow)
+			if mh != 0 || !primes && ml >= nativeTwoSquaresLimit {
+				break // the remainder only grows with smaller y
+			}
+			if c, d, ok := splitTwoSquares(ml); ok {
+				return [4]uint64{x, y, c, d}, true
+			}
+			if y == 0 {
+				break
+			}
+		}
+		if x == 0 {
+			return [4]uint64{}, false
+		}
+	}
+}
+
+// splitTwoSquares returns c, d with m = c^2 + d^2 if m is below nativeTwoSquaresLimit
+// and a sum of two squares, or a prime p = 1 (mod 4).
+func splitTwoSquares(m uint64) (uint64, uint64, bool) {
+	if m < nativeTwoSquaresLimit {
+		for c := isqrt64(m); 2*c*c >= m; c-- {
+			d := isqrt64(m - c*c)
+			if c*c+d*d == m {
+				return c, d, true
+			}
+		}
+		return 0, 0, false
+	}
+	if m&3 != 1 || !isPrimeUint64(m) {
+		return 0, 0, false
+	}
+	// s = u^((m-1)/4) is a square root of -1 for a quadratic non-residue u.
+	u := uint64(2)
+	for jacobiWord(u, m) != -1 {
+		u++
+	}
+	s := powMod64(u, m>>2, m)
+	if mulMod64(s, s, m) != m-1 {
+		return 0, 0, false
+	}
+	// Cornacchia: the first remainder of the Euclidean algorithm on m and s below
+	// sqrt(m) is c.
+	a, b, limit := m, s, isqrt64(m)
+	for b > limit {
+		a, b = b, a%b
+	}
+	d := isqrt64(m - b*b)
+	return b, d, b*b+d*d == m
+}
+
+// isPrimeUint64 is a deterministic primality test for n < 2^64.
+func isPrimeUint64(n uint64) bool {
+	for _, p := range []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37} {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+	if n < 41*41 {
+		return n > 1
+	}
+	bases := millerRabinBases64
+	if n < 1<<32 {
+		bases = millerRabinBases32
+	}
+	d := n - 1
+	r := bits.TrailingZeros64(d)
+	d >>= uint(r)
+	for _, a := range bases {
+		if a %= n; a == 0 {
+			continue
+		}
+		x := powMod64(a, d, n)
+		if x == 1 || x == n-1 {
+			continue
+		}
+		composite := true
+		for i := 1; i < r && composite; i++ {
+			x = mulMod64(x, x, n)
+			composite = x != n-1
+		}
+		if composite {
+			return false
+		}
+	}
+	return true
+}
+
+// mulMod64 returns a*b mod m for a, b < m.
+func mulMod64(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, r := bits.Div64(hi, lo, m)
+	return r
+}
+
+// powMod64 returns a^e mod m for a < m.
+func powMod64(a, e, m uint64) uint64 {
+	r := uint64(1)
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = mulMod64(r, a, m)
+		}
+		a = mulMod64(a, a, m)
+	}
+	return r
+}
+
+// isqrt64 returns floor(sqrt(n)).
+func isqrt64(n uint64) uint64 {
+	x := min(uint64(math.Sqrt(float64(n))), math.MaxUint32)
+	for x*x > n {
+		x--
+	}
+	for x < math.MaxUint32 && (x+1)*(x+1) <= n {
+		x++
+	}
+	return x
+}
+
+// isqrt128 returns floor(sqrt(n)) for n = hi*2^64 + lo.
+func isqrt128(hi, lo uint64) uint64 {
+	if hi == 0 {
+		return isqrt64(lo)
+	}
+	// Newton's iteration decreases monotonically from an upper bound. The float
+	// estimate is within 2^12 of the root, as its relative error is below 2^-52.
+	x := uint64(math.MaxUint64 - (1<<13 - 1))
+	if f := math.Sqrt(math.Ldexp(float64(hi), 64) + float64(lo)); f < float64(x) {
+		x = uint64(f)
+	}
+	x += 1<<13 - 1
+	for {
+		// Once n/x no longer fits in 64 bits, x < sqrt(n), which Newton's iteration
+		// only reaches at the root.
+		if hi >= x {
+			return x
+		}
+		q, _ := bits.Div64(hi, lo, x)
+		sum, carry := bits.Add64(x, q, 0)
+		y := sum>>1 | carry<<63
+		if y >= x {
+			return x
+		}
+		x = y
+	}
+}
+
+// rsh128 returns (hi*2^64 + lo) >> s for s < 128.
+func rsh128(hi, lo uint64, s uint) (uint64, uint64) {
+	if s >= 64 {
+		return 0, hi >> (s - 64)
+	}
+	if s == 0 {
+		return hi, lo
+	}
+	return hi >> s, lo>>s | hi<<(64-s)
+}
+
+// sortDescending sorts the four components in descending order.
+func sortDescending(w *[4]uint64) {
+	for i := 1; i < len(w); i++ {
+		for j := i; j > 0 && w[j] > w[j-1]; j-- {
+			w[j], w[j-1] = w[j-1], w[j]
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package lfs
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"lukechampine.com/frand"
+)
+
+// verifyUint128 reports whether the components of w are in descending order and
+// their squares sum to n = hi*2^64 + lo.
+func verifyUint128(hi, lo uint64, w [4]uint64) bool {
+	n := new(big.Int).SetUint64(hi)
+	n.Lsh(n, 64).Or(n, new(big.Int).SetUint64(lo))
+	var fi FourInt
+	for i := range fi {
+		if i > 0 && w[i] > w[i-1] {
+			return false
+		}
+		fi[i] = new(big.Int).SetUint64(w[i])
+	}
+	return Verify(n, fi)
+}
+
+func TestSolveUint64(t *testing.T) {
+	// Below nativeTwoSquaresLimit the search is exhaustive.
+	for n := uint64(0); n < 1<<12; n++ {
+		if got := SolveUint64(n); !verifyUint128(0, n, got) {
+			t.Fatalf("SolveUint64(%d) = %v", n, got)
+		}
+	}
+	tests := []uint64{
+		nativeTwoSquaresLimit - 1, nativeTwoSquaresLimit, 1 << 32, 1<<32 - 1, 1 << 62, 3 << 62,
+		math.MaxUint64, math.MaxUint64 - 1, math.MaxUint64 / 3 * 2,
+	}
+	for i := 0; i < 1000; i++ {
+		tests = append(tests, frand.Uint64n(math.MaxUint64)>>frand.Intn(64))
+	}
+	for _, n := range tests {
+		if got := SolveUint64(n); !verifyUint128(0, n, got) {
+			t.Fatalf("SolveUint64(%d) = %v", n, got)
+		}
+	}
+}
+
+func TestSolveUint128(t *testing.T) {
+	tests := [][2]uint64{
+		{1, 0}, {1, 1}, {1 << 62, 0}, {math.MaxUint64, math.MaxUint64}, {math.MaxUint64, 0},
+		{0, math.MaxUint64}, {math.MaxUint32, math.MaxUint64},
+	}
+	for i := 0; i < 1000; i++ {
+		tests = append(tests, [2]uint64{frand.Uint64n(math.MaxUint64) >> frand.Intn(64), frand.Uint64n(math.MaxUint64)})
+	}
+	for _, n := range tests {
+		if got := SolveUint128(n[0], n[1]); !verifyUint128(n[0], n[1], got) {
+			t.Fatalf("SolveUint128(%d, %d) = %v", n[0], n[1], got)
+		}
+	}
+	// The inputs are drawn in advance, as frand draws from a sync.Pool, which
+	// drops items at random under the race detector.
+	i := 0
+	if allocs := testing.AllocsPerRun(100, func() {
+		SolveUint128(tests[i%len(tests)][0], tests[i%len(tests)][1])
+		i++
+	}); allocs != 0 {
+		t.Errorf("SolveUint128 allocates %v times, want 0", allocs)
+	}
+}
+
+func TestSolverSolveNative(t *testing.T) {
+	s := NewSolver()
+	for _, bits := range []int{1, 64, 65, 128, 129} {
+		n := new(big.Int).Lsh(big1, uint(bits))
+		n.Sub(n, big.NewInt(17))
+		if n.Sign() < 0 {
+			n.SetInt64(5)
+		}
+		if got := s.Solve(n); !Verify(n, got) {
+			t.Fatalf("Solve(%v) = %v does not verify", n, got)
+		}
+	}
+}
+
+func TestIsPrimeUint64(t *testing.T) {
+	tests := []uint64{
+		0, 1, 2, 3, 4, 41 * 41, 1<<61 - 1, math.MaxUint64,
+		2047, 3215031751, 3825123056546413051, // strong pseudoprimes to small prime bases
+		18446744073709551557, // the largest prime below 2^64
+	}
+	for i := 0; i < 10000; i++ {
+		tests = append(tests, frand.Uint64n(math.MaxUint64)>>frand.Intn(64))
+	}
+	for _, n := range tests {
+		want := new(big.Int).SetUint64(n).ProbablyPrime(20)
+		if got := isPrimeUint64(n); got != want {
+			t.Fatalf("isPrimeUint64(%d) = %t, want %t", n, got, want)
+		}
+	}
+}
+
+func BenchmarkSolveNative(b *testing.B) {
+	s := NewSolver()
+	for _, bits := range []int{64, 128} {
+		n := new(big.Int).Lsh(big1, uint(bits-1))
+		n.Add(n, frand.BigIntn(n))
+		b.Run(fmt.Sprintf("native/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.Solve(n)
+			}
+		})
+		b.Run(fmt.Sprintf("search/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.SolveBasic(n)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package lfs
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+)
+
+// SolvePath identifies how a solve computes its representation.
+type SolvePath int
+
+const (
+	// PathCached answers the solve from the result cache.
+	PathCached SolvePath = iota
+	// PathNative solves inputs below 2^128 with the arithmetic of SolveUint128.
+	PathNative
+	// PathPrecomputed looks up the odd component in a table of precomputed representations.
+	PathPrecomputed
+	// PathBasic runs the randomized search of the basic algorithm.
+	PathBasic
+	// PathFCM runs the randomized search of the FCM algorithm.
+	PathFCM
+	// PathHedged races the basic algorithm against the FCM algorithm, which then
+	// report their own paths as well.
+	PathHedged
+)
+
+var solvePathNames = [...]string{"cached", "native", "precomputed", "basic", "fcm", "hedged"}
+
+// String returns the lower-case name of the path.
+func (p SolvePath) String() string {
+	if p < 0 || int(p) >= len(solvePathNames) {
+		return "unknown"
+	}
+	return solvePathNames[p]
+}
+
+// MarshalText implements encoding.TextMarshaler with the name of the path.
+func (p SolvePath) MarshalText() ([]byte, error) {
+	if p < 0 || int(p) >= len(solvePathNames) {
+		return nil, fmt.Errorf("lfs: unknown solve path %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for the names of MarshalText.
+func (p *SolvePath) UnmarshalText(text []byte) error {
+	for i, name := range solvePathNames {
+		if string(text) == name {
+			*p = SolvePath(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("lfs: unknown solve path %q", text)
+}
+
+// Observer receives callbacks at the phases of a solve, for tracing and metrics.
+// OnSolveStart and OnSolveEnd enclose every solve of Solve, SolveBasic and
+// SolveWithStats, and every input of SolveVector, which reports empty stats as
+// its inputs share the workers. The candidate callbacks are invoked concurrently
+// by the search workers, once per candidate, so they must be safe for concurrent
+// use and cheap. Embed NopObserver to implement only some of the callbacks.
+type Observer interface {
+	// OnSolveStart is called before n is solved.
+	OnSolveStart(n *big.Int)
+	// OnPathSelected is called once the solve of n has chosen its path.
+	OnPathSelected(n *big.Int, path SolvePath)
+	// OnCandidate is called for every candidate prime drawn by a search worker.
+	OnCandidate()
+	// OnPrimeFound is called for a candidate p that passed the primality test and
+	// has a square root of -1.
+	OnPrimeFound(p *big.Int)
+	// OnGCDRejected is called if the split of a found prime p failed, which means
+	// that p is composite and the search continues.
+	OnGCDRejected(p *big.Int)
+	// OnSolveEnd is called once the solve of n has returned with the given stats
+	// and error after elapsed.
+	OnSolveEnd(n *big.Int, stats Stats, elapsed time.Duration, err error)
+}
+
+// NopObserver implements Observer with callbacks that do nothing.
+type NopObserver struct{}
+
+func (NopObserver) OnSolveStart(*big.Int)                            {}
+func (NopObserver) OnPathSelected(*big.Int, SolvePath)               {}
+func (NopObserver) OnCandidate()                                     {}
+func (NopObserver) OnPrimeFound(*big.Int)                            {}
+func (NopObserver) OnGCDRejected(*big.Int)                           {}
+func (NopObserver) OnSolveEnd(*big.Int, Stats, time.Duration, error) {}
+
+// observePath reports the path selected for n to the observer, if any, and to
+// the logger.
+func (s *Solver) observePath(n *big.Int, path SolvePath) {
+	if s.Observer != nil {
+		s.Observer.OnPathSelected(n, path)
+	}
+	if l := s.logger(); l.Enabled(context.Background(), slog.LevelDebug) {
+		l.Debug("lfs: path selected", slog.Int("bits", n.BitLen()), slog.String("path", path.String()))
+	}
+}
+
+// observeCandidate reports a candidate drawn by the worker to the observer, if any.
+func (w *searchWorker) observeCandidate() {
+	if o := w.sp.observer; o != nil {
+		o.OnCandidate()
+	}
+}
+
+// observePrime reports a prime found by the worker to the observer, if any.
+func (w *searchWorker) observePrime(p *big.Int) {
+	if o := w.sp.observer; o != nil {
+		o.OnPrimeFound(p)
+	}
+}
+
+// ExpvarObserver is an Observer that counts the phases of solves in an expvar.Map,
+// which is served as JSON by the /debug/vars handler of the expvar package.
+type ExpvarObserver struct {
+	// Map holds the counters: solves, errors, in_flight, elapsed_ns (the total
+	// time spent solving), candidates, primes, gcd_rejected and path_<name> for
+	// every SolvePath.
+	Map *expvar.Map
+
+	solves, errors, inFlight, elapsed *expvar.Int
+	candidates, primes, gcdRejected   *expvar.Int
+	paths                             [len(solvePathNames)]*expvar.Int
+}
+
+// NewExpvarObserver returns an ExpvarObserver whose counters are published under
+// name. Like expvar.Publish, it panics if name is already in use.
+func NewExpvarObserver(name string) *ExpvarObserver {
+	o := &ExpvarObserver{Map: expvar.NewMap(name)}
+	counter := func(key string) *expvar.Int {
+		v := new(expvar.Int)
+		o.Map.Set(key, v)
+		return v
+	}
+	o.solves = counter("solves")
+	o.errors = counter("errors")
+	o.inFlight = counter("in_flight")
+	o.elapsed = counter("elapsed_ns")
+	o.candidates = counter("candidates")
+	o.primes = counter("primes")
+	o.gcdRejected = counter("gcd_rejected")
+	for i := range o.paths {
+		o.paths[i] = counter("path_" + SolvePath(i).String())
+	}
+	return o
+}
+
+func (o *ExpvarObserver) OnSolveStart(*big.Int) {
+	o.inFlight.Add(1)
+}
+
+func (o *ExpvarObserver) OnPathSelected(_ *big.Int, path SolvePath) {
+	if path >= 0 && int(path) < len(o.paths) {
+		o.paths[path].Add(1)
+	}
+}
+
+func (o *ExpvarObserver) OnCandidate() {
+	o.candidates.Add(1)
+}
+
+func (o *ExpvarObserver) OnPrimeFound(*big.Int) {
+	o.primes.Add(1)
+}
+
+func (o *ExpvarObserver) OnGCDRejected(*big.Int) {
+	o.gcdRejected.Add(1)
+}
+
+func (o *ExpvarObserver) OnSolveEnd(_ *big.Int, _ Stats, elapsed time.Duration, err error) {
+	o.inFlight.Add(-1)
+	o.solves.Add(1)
+	o.elapsed.Add(elapsed.Nanoseconds())
+	if err != nil {
+		o.errors.Add(1)
+	}
+}
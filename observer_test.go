@@ -0,0 +1,131 @@
+package lfs
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingObserver records the callbacks it receives.
+type recordingObserver struct {
+	NopObserver
+	mu         sync.Mutex
+	paths      []SolvePath
+	starts     int
+	ends       int
+	candidates atomic.Uint64
+	primes     atomic.Uint64
+}
+
+func (o *recordingObserver) OnSolveStart(*big.Int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.starts++
+}
+
+func (o *recordingObserver) OnPathSelected(_ *big.Int, path SolvePath) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.paths = append(o.paths, path)
+}
+
+func (o *recordingObserver) OnCandidate() {
+	o.candidates.Add(1)
+}
+
+func (o *recordingObserver) OnPrimeFound(*big.Int) {
+	o.primes.Add(1)
+}
+
+func (o *recordingObserver) OnSolveEnd(*big.Int, Stats, time.Duration, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ends++
+}
+
+func TestWithObserver(t *testing.T) {
+	large := new(big.Int).Sub(new(big.Int).Lsh(big1, 256), big.NewInt(189))
+	tests := []struct {
+		name  string
+		n     *big.Int
+		opts  []Option
+		paths []SolvePath
+	}{
+		{name: "native", n: big.NewInt(1 << 40), paths: []SolvePath{PathNative}},
+		{name: "precomputed", n: big.NewInt(0), paths: []SolvePath{PathPrecomputed}},
+		{name: "basic", n: large, paths: []SolvePath{PathBasic}},
+		{name: "fcm", n: large, opts: []Option{WithFCMThreshold(big1)}, paths: []SolvePath{PathFCM}},
+		{name: "cached", n: large, opts: []Option{WithResultCache(1)}, paths: []SolvePath{PathBasic, PathCached}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &recordingObserver{}
+			s := NewSolver(append(tt.opts, WithNumRoutines(1), WithObserver(o))...)
+			var stats Stats
+			for range tt.paths {
+				_, st, err := s.SolveWithStats(context.Background(), tt.n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				stats = stats.merge(st)
+			}
+			if o.starts != len(tt.paths) || o.ends != len(tt.paths) {
+				t.Errorf("observed %d starts and %d ends, want %d", o.starts, o.ends, len(tt.paths))
+			}
+			if len(o.paths) != len(tt.paths) {
+				t.Fatalf("observed paths %v, want %v", o.paths, tt.paths)
+			}
+			for i := range o.paths {
+				if o.paths[i] != tt.paths[i] {
+					t.Errorf("observed paths %v, want %v", o.paths, tt.paths)
+				}
+			}
+			// A single worker flushes its counters before it returns the result.
+			if got := o.candidates.Load(); got != stats.Candidates {
+				t.Errorf("observed %d candidates, stats report %d", got, stats.Candidates)
+			}
+			if got, want := o.primes.Load(), stats.Primes-stats.CompositeRetries; got != want {
+				t.Errorf("observed %d primes, want %d", got, want)
+			}
+		})
+	}
+}
+
+// expvarObserverRuns numbers the runs of TestExpvarObserver, as expvar names are
+// process-global and cannot be published twice, for example with -count=2.
+var expvarObserverRuns atomic.Int64
+
+func TestExpvarObserver(t *testing.T) {
+	o := NewExpvarObserver(fmt.Sprintf("lfs_test_observer_%d", expvarObserverRuns.Add(1)))
+	s := NewSolver(WithObserver(o))
+	large := new(big.Int).Sub(new(big.Int).Lsh(big1, 256), big.NewInt(189))
+	if _, err := s.SolveVector(context.Background(), []*big.Int{large, big.NewInt(7)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.SolveWithStats(context.Background(), big.NewInt(-1)); err == nil {
+		t.Fatal("SolveWithStats(-1) succeeded")
+	}
+	want := map[string]int64{
+		"solves":           3,
+		"errors":           1,
+		"in_flight":        0,
+		"path_basic":       1,
+		"path_precomputed": 1,
+	}
+	for key, v := range want {
+		if got := o.Map.Get(key).(*expvar.Int).Value(); got != v {
+			t.Errorf("%s = %d, want %d", key, got, v)
+		}
+	}
+	if got := o.Map.Get("candidates").(*expvar.Int).Value(); got == 0 {
+		t.Error("candidates = 0, want the candidates of the search")
+	}
+	if got := o.Map.Get("primes").(*expvar.Int).Value(); got == 0 {
+		t.Error("primes = 0, want at least one")
+	}
+}
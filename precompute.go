@@ -1,12 +1,19 @@
 package lfs
 
 import (
+	"math"
 	"math/big"
+	"math/bits"
+	"sync"
 
 	comp "github.com/txaty/go-bigcomplex"
 )
 
-const precomputeLmt = 20
+const (
+	precomputeLmt = 20
+	// maxPrecomputeLimit caps the size of the lazily built small odd n table.
+	maxPrecomputeLimit = 1 << 20
+)
 
 var (
 	// precomputedHurwitzGCRDs contains precomputed Hurwitz GCRDs for small integers.
@@ -35,8 +42,93 @@ var (
 	}
 	bigPrecomputeLmt = big.NewInt(precomputeLmt)
 	tinyPrimeProd    = big.NewInt(210) // Product of small primes: 2*3*5*7
+
+	// smallTables caches the lazily built representation tables, keyed by limit.
+	smallTables = sync.Map{}
 )
 
+// smallTable holds four-square representations of all odd n up to its limit.
+type smallTable struct {
+	once  sync.Once
+	limit int
+	reps  [][4]uint16 // reps[n/2] is the representation of the odd n
+}
+
+// lookupSmallTable returns the Hurwitz integer representing the odd n if n does not
+// exceed limit, building the table for limit on first use.
+func lookupSmallTable(n *big.Int, limit int) (*comp.HurwitzInt, bool) {
+	if limit > maxPrecomputeLimit {
+		limit = maxPrecomputeLimit
+	}
+	if limit <= precomputeLmt || !n.IsInt64() || n.Int64() > int64(limit) {
+		return nil, false
+	}
+	v, _ := smallTables.LoadOrStore(limit, &smallTable{limit: limit})
+	table := v.(*smallTable)
+	table.once.Do(table.build)
+	rep := table.reps[n.Int64()/2]
+	return comp.NewHurwitzInt(
+		big.NewInt(int64(rep[0])),
+		big.NewInt(int64(rep[1])),
+		big.NewInt(int64(rep[2])),
+		big.NewInt(int64(rep[3])),
+		false,
+	), true
+}
+
+// usesSmallTable reports whether the odd component of the positive n is served
+// from the table of PrecomputeLimit. A configured table takes precedence over the
+// native solver and the FCM algorithm, which do not consult it.
+func (s *Solver) usesSmallTable(n *big.Int) bool {
+	limit := min(s.PrecomputeLimit, maxPrecomputeLimit)
+	if limit <= precomputeLmt {
+		return false
+	}
+	tz := n.TrailingZeroBits()
+	if n.BitLen()-int(tz) > bits.Len(uint(limit)) {
+		return false
+	}
+	return new(big.Int).Rsh(n, tz).Int64() <= int64(limit)
+}
+
+// build fills the table. Every odd n is written as n = (a^2 + b^2) + (c^2 + d^2)
+// using a lookup table of the sums of two squares up to the limit.
+func (t *smallTable) build() {
+	twoSquares := make([][2]uint16, t.limit+1)
+	isTwoSquares := make([]bool, t.limit+1)
+	for c := 0; c*c <= t.limit; c++ {
+		for d := 0; d <= c && c*c+d*d <= t.limit; d++ {
+			if !isTwoSquares[c*c+d*d] {
+				twoSquares[c*c+d*d] = [2]uint16{uint16(c), uint16(d)}
+				isTwoSquares[c*c+d*d] = true
+			}
+		}
+	}
+	t.reps = make([][4]uint16, t.limit/2+1)
+	for n := 1; n <= t.limit; n += 2 {
+		t.reps[n/2] = findSmallRepresentation(n, twoSquares, isTwoSquares)
+	}
+}
+
+// findSmallRepresentation searches the largest a, b for which n - a^2 - b^2 is a
+// sum of two squares.
+func findSmallRepresentation(n int, twoSquares [][2]uint16, isTwoSquares []bool) [4]uint16 {
+	for a := int(math.Sqrt(float64(n))); a >= 0; a-- {
+		for b := a; b >= 0; b-- {
+			r := n - a*a - b*b
+			if r < 0 {
+				continue
+			}
+			if isTwoSquares[r] {
+				cd := twoSquares[r]
+				return [4]uint16{uint16(a), uint16(b), cd[0], cd[1]}
+			}
+		}
+	}
+	// Unreachable by Lagrange's four-square theorem.
+	return [4]uint16{}
+}
+
 // log2 returns the floor of the base‑2 logarithm of n.
 func log2(n *big.Int) int {
 	return n.BitLen() - 1
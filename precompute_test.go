@@ -0,0 +1,45 @@
+package lfs
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestLookupSmallTable(t *testing.T) {
+	const limit = 1 << 12
+	for n := int64(precomputeLmt + 1); n <= limit; n += 2 {
+		gcrd, ok := lookupSmallTable(big.NewInt(n), limit)
+		if !ok {
+			t.Fatalf("lookupSmallTable(%d) not found", n)
+		}
+		if got := NewFourInt(gcrd.ValInt()); !Verify(big.NewInt(n), got) {
+			t.Fatalf("lookupSmallTable(%d) = %v does not verify", n, got)
+		}
+	}
+	if _, ok := lookupSmallTable(big.NewInt(limit+1), limit); ok {
+		t.Errorf("lookupSmallTable(%d) found beyond the limit", limit+1)
+	}
+	if _, ok := lookupSmallTable(big.NewInt(precomputeLmt+1), precomputeLmt); ok {
+		t.Errorf("lookupSmallTable() should be disabled at the default limit")
+	}
+}
+
+func TestWithPrecomputeLimit(t *testing.T) {
+	s := NewSolver(WithPrecomputeLimit(1 << 16))
+	for _, n := range []int64{21, 1023, 4096 * 3, 65535, 65536} {
+		got := s.SolveBasic(big.NewInt(n))
+		if !Verify(big.NewInt(n), got) {
+			t.Errorf("SolveBasic(%d) = %v does not verify", n, got)
+		}
+		// The table takes precedence over the native solver and the FCM algorithm.
+		for _, m := range []*big.Int{big.NewInt(n), new(big.Int).Lsh(big.NewInt(n), 300)} {
+			if got := s.Solve(m); !Verify(m, got) {
+				t.Errorf("Solve(%d) = %v does not verify", m, got)
+			}
+		}
+	}
+	// The values are served from the table, without a search.
+	if stats := s.Stats(); stats.Searches != 0 || stats.Candidates != 0 {
+		t.Errorf("Stats() = %+v, want no search", stats)
+	}
+}
@@ -0,0 +1,112 @@
+package lfs
+
+import (
+	"math"
+	"math/big"
+	"time"
+)
+
+// defaultProgressInterval is the interval between progress reports if
+// ProgressInterval is not set.
+const defaultProgressInterval = 500 * time.Millisecond
+
+// Progress describes the state of a running randomized search.
+type Progress struct {
+	// Bits is the approximate bit length of the candidate primes.
+	Bits int
+	// Attempts is the number of candidates examined by all workers so far.
+	Attempts uint64
+	// ExpectedAttempts is the expected number of candidates per prime found,
+	// estimated from the density of primes among the candidates.
+	ExpectedAttempts float64
+	// Elapsed is the time since the search started.
+	Elapsed time.Duration
+	// Remaining is the estimated time until a prime is found. As the candidates
+	// are drawn independently, it is the time of ExpectedAttempts candidates at
+	// the rate observed so far, regardless of how many have been examined.
+	Remaining time.Duration
+}
+
+// expectedAttempts estimates the number of candidates of the given bit length per
+// prime, if every candidate is coprime to the small prime factors of coprime: by
+// the prime number theorem, a random integer around 2^bits is prime with
+// probability 1/(bits*ln 2), and excluding the multiples of a prime q raises it
+// by q/(q-1).
+func expectedAttempts(bits int, coprime *big.Int) float64 {
+	e := float64(bits) * math.Ln2
+	if coprime.Bit(0) == 0 {
+		e /= 2
+	}
+	for _, q := range oddPrimesBelow(qnrSearchBound) {
+		if modWord(coprime, q) == 0 {
+			e *= float64(q-1) / float64(q)
+		}
+	}
+	return max(e, 1)
+}
+
+// initProgress enables the progress reports of the search sp for candidates
+// p = preP*k - 1 with k below randLimit, which are coprime to preP.
+func (s *Solver) initProgress(sp *searchParams, preP, randLimit *big.Int) {
+	if s.Progress == nil {
+		return
+	}
+	interval := s.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	sp.progress = s.Progress
+	sp.progressInterval = interval
+	sp.progressBits = preP.BitLen() + randLimit.BitLen()
+	sp.expectedAttempts = expectedAttempts(sp.progressBits, preP)
+	sp.nextProgress.Store(sp.start.Add(interval).UnixNano())
+}
+
+// useFCMDensity adjusts the progress estimate of sp to the candidates
+// p = preP - l^2 of the FCM algorithm. They are odd, and an odd prime q not
+// dividing preP divides p for 1 + Jacobi(preP|q) of the q residues of l instead
+// of one. As l is drawn below 2*randLimit, only the draws with l^2 < preP yield
+// a candidate.
+func (sp *searchParams) useFCMDensity() {
+	if sp.progress == nil {
+		return
+	}
+	sp.progressBits = sp.preP.BitLen()
+	sp.expectedAttempts = expectedAttempts(sp.progressBits, big2)
+	for _, q := range oddPrimesBelow(qnrSearchBound) {
+		if r := modWord(sp.preP, q); r != 0 {
+			fq := float64(q)
+			sp.expectedAttempts *= (1 - 1/fq) / (1 - float64(1+jacobiWord(r, q))/fq)
+		}
+	}
+	root := new(big.Float).SetInt(new(big.Int).Sqrt(sp.preP))
+	draws := new(big.Float).SetInt(new(big.Int).Lsh(sp.randLimit, 1))
+	if valid, _ := root.Quo(root, draws).Float64(); valid > 0 && valid < 1 {
+		sp.expectedAttempts /= valid
+	}
+}
+
+// reportProgress calls the progress function of sp if the report interval has
+// passed since the last report. Of the workers calling it concurrently, only the
+// first one after the interval reports.
+func (sp *searchParams) reportProgress() {
+	if sp.progress == nil {
+		return
+	}
+	now := time.Now()
+	next := sp.nextProgress.Load()
+	if now.UnixNano() < next || !sp.nextProgress.CompareAndSwap(next, now.Add(sp.progressInterval).UnixNano()) {
+		return
+	}
+	p := Progress{
+		Bits:             sp.progressBits,
+		Attempts:         sp.search.candidates.Load(),
+		ExpectedAttempts: sp.expectedAttempts,
+		Elapsed:          now.Sub(sp.start),
+	}
+	if p.Attempts > 0 {
+		perAttempt := float64(p.Elapsed) / float64(p.Attempts)
+		p.Remaining = time.Duration(perAttempt * p.ExpectedAttempts)
+	}
+	sp.progress(p)
+}
@@ -0,0 +1,80 @@
+package lfs
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExpectedAttempts(t *testing.T) {
+	tests := []struct {
+		bits    int
+		coprime *big.Int
+		want    float64
+	}{
+		{bits: 1000, coprime: big1, want: 1000 * math.Ln2},
+		{bits: 1000, coprime: big2, want: 500 * math.Ln2},
+		{bits: 1000, coprime: tinyPrimeProd, want: 500 * math.Ln2 * 2 / 3 * 4 / 5 * 6 / 7},
+		{bits: 1, coprime: tinyPrimeProd, want: 1},
+	}
+	for _, tt := range tests {
+		if got := expectedAttempts(tt.bits, tt.coprime); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("expectedAttempts(%d, %v) = %v, want %v", tt.bits, tt.coprime, got, tt.want)
+		}
+	}
+}
+
+func TestWithProgress(t *testing.T) {
+	n := new(big.Int).Sub(new(big.Int).Lsh(big1, 1024), big.NewInt(105))
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "basic"},
+		{name: "fcm", opts: []Option{WithFCMThreshold(big1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				reports []Progress
+			)
+			s := NewSolver(append(tt.opts,
+				// Without primes, the search runs until the attempt budget is used up.
+				WithPrimalityTest(func(*big.Int) bool { return false }),
+				WithMaxAttempts(4096),
+				WithNumRoutines(2),
+				WithProgressInterval(time.Nanosecond),
+				WithProgress(func(p Progress) {
+					mu.Lock()
+					defer mu.Unlock()
+					reports = append(reports, p)
+				}),
+			)...)
+			if _, _, err := s.SolveWithStats(context.Background(), n); !errors.Is(err, ErrSearchExhausted) {
+				t.Fatalf("SolveWithStats() error = %v, want %v", err, ErrSearchExhausted)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(reports) == 0 {
+				t.Fatal("no progress reported")
+			}
+			for _, p := range reports {
+				if p.Attempts == 0 || p.Attempts > 4096+2*budgetCheckInterval || p.Bits < 1024 ||
+					p.ExpectedAttempts < 100 || p.ExpectedAttempts > 10000 || p.Elapsed <= 0 || p.Remaining <= 0 {
+					t.Errorf("invalid progress %+v", p)
+				}
+			}
+		})
+	}
+
+	// Without a progress function, workers do not flush their counters early.
+	sp := NewSolver().newSearchParams(n, big.NewInt(1<<10))
+	if sp.progress != nil || sp.expectedAttempts != 0 {
+		t.Errorf("progress enabled without WithProgress")
+	}
+}
@@ -0,0 +1,113 @@
+package lfs
+
+import (
+	"container/list"
+	"log/slog"
+	"math/big"
+	"sync"
+)
+
+// resultCache is a concurrency-safe LRU cache of representations keyed on n.
+// Entries are cloned on the way in and out, so that callers cannot modify them.
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   list.List // front is the most recently used entry
+}
+
+// resultEntry is an element of resultCache.order.
+type resultEntry struct {
+	key string
+	fi  FourInt
+}
+
+// resultKey returns the cache key of a non-negative n.
+func resultKey(n *big.Int) string {
+	return string(n.Bytes())
+}
+
+// get returns a copy of the representation of n and marks it as recently used.
+func (c *resultCache) get(n *big.Int) (FourInt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[resultKey(n)]
+	if !ok {
+		return FourInt{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*resultEntry).fi.Clone(), true
+}
+
+// put stores a copy of the representation of n, evicting the least recently used
+// entries beyond size. It returns the number of cached entries and of evictions.
+func (c *resultCache) put(n *big.Int, fi FourInt, size int) (entries, evicted int) {
+	key := resultKey(n)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*resultEntry).fi = fi.Clone()
+		c.order.MoveToFront(el)
+	} else {
+		c.entries[key] = c.order.PushFront(&resultEntry{key: key, fi: fi.Clone()})
+	}
+	for c.order.Len() > size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*resultEntry).key)
+		evicted++
+	}
+	return c.order.Len(), evicted
+}
+
+// len returns the number of cached representations.
+func (c *resultCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// reset removes all cached representations.
+func (c *resultCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	c.order.Init()
+}
+
+// cachedResult returns a copy of the cached representation of n if the result
+// cache is enabled, counting the hit or miss.
+func (s *Solver) cachedResult(n *big.Int) (FourInt, bool) {
+	if s.ResultCacheSize <= 0 {
+		return FourInt{}, false
+	}
+	fi, ok := s.results.get(n)
+	if ok {
+		s.stats.cacheHits.Add(1)
+	} else {
+		s.stats.cacheMisses.Add(1)
+	}
+	return fi, ok
+}
+
+// cacheResult stores the representation fi of n if the result cache is enabled
+// and, with CacheVerifiedOnly, if fi passes Verify.
+func (s *Solver) cacheResult(n *big.Int, fi FourInt) {
+	if s.ResultCacheSize <= 0 || s.CacheVerifiedOnly && !Verify(n, fi) {
+		return
+	}
+	entries, evicted := s.results.put(n, fi, s.ResultCacheSize)
+	s.logger().Debug("lfs: result cached", slog.Int("bits", n.BitLen()), slog.Int("entries", entries), slog.Int("evicted", evicted))
+}
+
+// CachedResults returns the number of representations held by the result cache.
+func (s *Solver) CachedResults() int {
+	return s.results.len()
+}
+
+// ResetResultCache removes all representations from the result cache.
+func (s *Solver) ResetResultCache() {
+	s.results.reset()
+}
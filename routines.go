@@ -0,0 +1,62 @@
+package lfs
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+const (
+	// adaptiveWorkPerRoutine is the expected work of a search, in units of a
+	// primality test on a single word, that justifies one more goroutine. It
+	// corresponds to roughly 50µs, well above the cost of starting a worker.
+	adaptiveWorkPerRoutine = 1 << 6
+	// adaptiveMinSamples is the number of completed searches of a size class from
+	// which the observed candidates per success replace the prior estimate.
+	adaptiveMinSamples = 8
+)
+
+// routineModel records the observed cost of completed searches per size class,
+// where the size class of a search is the bit length of the bit length of p.
+type routineModel struct {
+	classes [bits.UintSize]struct {
+		searches   atomic.Uint64
+		candidates atomic.Uint64
+	}
+}
+
+// observe records a completed search for a p of bitLen bits that took the given
+// number of candidates.
+func (m *routineModel) observe(bitLen int, candidates uint64) {
+	c := &m.classes[bits.Len(uint(bitLen))]
+	c.searches.Add(1)
+	c.candidates.Add(candidates)
+}
+
+// candidatesPerSuccess returns the expected number of candidates until a search
+// for a p of bitLen bits succeeds. Until enough searches of the size class have
+// been observed, it assumes that one in bitLen/4 candidates succeeds, which is
+// the density of primes among the sieved candidates up to a small factor.
+func (m *routineModel) candidatesPerSuccess(bitLen int) uint64 {
+	c := &m.classes[bits.Len(uint(bitLen))]
+	if searches := c.searches.Load(); searches >= adaptiveMinSamples {
+		return max(1, c.candidates.Load()/searches)
+	}
+	return uint64(max(1, bitLen/4))
+}
+
+// numRoutines returns the number of workers used to search for a p of bitLen bits,
+// given a budget of limit workers. It is never below one. With AdaptiveRoutines,
+// the count is reduced below the budget for small inputs, where starting the
+// workers would cost more than the search itself: the expected work is estimated
+// from the observed candidates per success and the cost of a primality test,
+// which grows quadratically with the number of words of p.
+func (s *Solver) numRoutines(bitLen, limit int) int {
+	limit = max(1, limit)
+	n := limit
+	if s.AdaptiveRoutines {
+		words := uint64(max(1, bitLen/bits.UintSize))
+		work := s.routineModel.candidatesPerSuccess(bitLen) * words * words
+		n = int(min(uint64(limit), max(1, work/adaptiveWorkPerRoutine)))
+	}
+	return n
+}
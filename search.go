@@ -0,0 +1,427 @@
+package lfs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	comp "github.com/txaty/go-bigcomplex"
+	"lukechampine.com/frand"
+)
+
+// qnrSearchBound bounds the small primes tried as quadratic non-residues.
+const qnrSearchBound = 1 << 12
+
+// errNoSqrtMinusOne and errSplitFailed describe a candidate that passed the
+// primality test but turned out to be composite. They are logged by the workers,
+// which then move on to the next candidate.
+var (
+	errNoSqrtMinusOne = errors.New("lfs: candidate prime has no square root of -1")
+	errSplitFailed    = errors.New("lfs: candidate prime does not split into two squares")
+)
+
+// budgetCheckInterval is the number of candidates after which a worker flushes
+// its counters and checks the attempt budget of the search.
+const budgetCheckInterval = 64
+
+// searchParams carries the settings and the shared state of a single randomized
+// candidate search. It is created once per solve and read by all workers.
+type searchParams struct {
+	preP      *big.Int            // candidates p are derived from preP
+	randLimit *big.Int            // upper bound of the random draws
+	sieve     *candidateSieve     // small-prime sieve for candidates derived from preP
+	isPrime   func(*big.Int) bool // primality test applied to candidates
+	stats     *searchStats        // counters of the Solver updated by the workers
+	search    searchStats         // counters of this search only
+
+	maxAttempts uint64        // candidates after which the search gives up, 0 for no limit
+	exhausted   chan struct{} // closed once the attempt budget has been used up
+	exhaustOnce sync.Once
+	workers     sync.WaitGroup // workers started for this search
+
+	fastGCDBits int         // bit length from which the Lehmer-style GCDs are used, 0 to disable
+	split       SplitMethod // how candidate primes are split into a sum of two squares
+
+	newRNG   func() *frand.RNG // random source of a new worker
+	observer Observer          // notified of candidates and primes, may be nil
+	logger   *slog.Logger      // receives worker and search records
+	start    time.Time         // creation time of the search
+
+	// Progress reports, enabled if progress is not nil.
+	progress         func(Progress)
+	progressInterval time.Duration
+	progressBits     int          // approximate bit length of the candidates
+	expectedAttempts float64      // expected candidates per prime
+	nextProgress     atomic.Int64 // time of the next report in Unix nanoseconds
+}
+
+// searchResult is the outcome of a successful candidate search: the prime p, s
+// with s^2 = -1 (mod p), the Gaussian GCD a + b*i of p and s + i or s - i, and l
+// in the FCM algorithm. The witnesses p, s and l are kept for certificates.
+type searchResult struct {
+	gcd     *comp.GaussianInt
+	p, s, l *big.Int
+}
+
+// searchWorker holds the per-worker scratch state of a candidate search. Reusing
+// it across candidates keeps the steady-state candidate loop, from drawing the
+// random multiplier to the primality test, free of heap allocations.
+type searchWorker struct {
+	sp    *searchParams
+	rng   *frand.RNG
+	buf   []byte   // random bytes for draws above 64 bits
+	k     *big.Int // random multiplier k, or l in the FCM algorithm
+	p     *big.Int // candidate prime
+	opt   *big.Int // temporary value
+	stats workerStats
+
+	examined uint64 // candidates flushed by the worker so far
+
+	// State of the sequential walk used by SievedSearch.
+	k0        *big.Int // multiplier of the first candidate in the window
+	window    []bool   // composite marks of the current window
+	windowPos int      // next index of the window to examine
+
+	// Montgomery context of the current candidate prime in math_big_pure_go and
+	// wasm builds, and empty in other builds, see sqrtMinusOne.
+	mont workerMont
+}
+
+// workerStats holds the counters of a single worker, flushed into the shared
+// searchStats to avoid contention on every candidate.
+type workerStats struct {
+	candidates       uint64
+	sieved           uint64
+	primes           uint64
+	compositeRetries uint64
+}
+
+// newSearchWorker returns a worker with its own random number generator and scratch values.
+func newSearchWorker(sp *searchParams) *searchWorker {
+	rng := frand.New
+	if sp != nil {
+		rng = sp.newRNG
+	}
+	w := &searchWorker{
+		sp:  sp,
+		rng: rng(),
+		k:   new(big.Int),
+		p:   new(big.Int),
+		opt: new(big.Int),
+	}
+	if sp != nil {
+		w.logStart()
+	}
+	return w
+}
+
+// bind points the worker at new search parameters, keeping its scratch values.
+// Any sequential walk is restarted, as it belongs to the previous parameters.
+func (w *searchWorker) bind(sp *searchParams) {
+	w.flushStats()
+	w.sp = sp
+	w.window = w.window[:0]
+	w.windowPos = 0
+}
+
+// randIntn sets z to a uniform random integer in [0, limit) without allocating
+// once z and the byte buffer have grown to size.
+func (w *searchWorker) randIntn(z, limit *big.Int) *big.Int {
+	if limit.IsUint64() {
+		return z.SetUint64(w.rng.Uint64n(limit.Uint64()))
+	}
+	bitLen := limit.BitLen()
+	n := (bitLen + 7) / 8
+	if cap(w.buf) < n {
+		w.buf = make([]byte, n)
+	}
+	buf := w.buf[:n]
+	mask := byte(0xff >> (8*n - bitLen))
+	for {
+		_, _ = w.rng.Read(buf)
+		buf[0] &= mask
+		if z.SetBytes(buf).Cmp(limit) < 0 {
+			return z
+		}
+	}
+}
+
+// flushStats adds the worker's counters to the shared stats and resets them.
+func (w *searchWorker) flushStats() {
+	w.sp.search.add(&w.stats)
+	w.sp.stats.add(&w.stats)
+	w.examined += w.stats.candidates
+	w.stats = workerStats{}
+}
+
+// budgetExceeded reports whether the attempt budget of the search has been used
+// up, in which case the worker should stop. The worker's counters are flushed
+// every budgetCheckInterval candidates, so that the shared count lags behind by
+// at most that many candidates per worker, and the progress of the search is
+// reported at the same points.
+func (w *searchWorker) budgetExceeded() bool {
+	maxAttempts := w.sp.maxAttempts
+	interval := uint64(budgetCheckInterval)
+	if maxAttempts > 0 {
+		interval = min(interval, maxAttempts)
+	}
+	if maxAttempts == 0 && w.sp.progress == nil || w.stats.candidates < interval {
+		return false
+	}
+	w.flushStats()
+	w.sp.reportProgress()
+	if maxAttempts == 0 || w.sp.search.candidates.Load() < maxAttempts {
+		return false
+	}
+	w.sp.exhaustOnce.Do(func() {
+		close(w.sp.exhausted)
+	})
+	return true
+}
+
+// newSearchParams returns the search parameters for candidates derived from preP.
+func (s *Solver) newSearchParams(preP, randLimit *big.Int) *searchParams {
+	sp := &searchParams{
+		preP:      preP,
+		randLimit: randLimit,
+		sieve:     newCandidateSieve(preP, s.SieveBound),
+		isPrime:   s.primalityTest(),
+		stats:     &s.stats,
+
+		maxAttempts: s.MaxAttempts,
+		exhausted:   make(chan struct{}),
+		fastGCDBits: s.FastGCDThreshold,
+		split:       s.SplitMethod,
+		newRNG:      s.newRNG,
+		observer:    s.Observer,
+		logger:      s.logger(),
+		start:       time.Now(),
+	}
+	s.initProgress(sp, preP, randLimit)
+	return sp
+}
+
+// newRNG returns the random source of a new worker. With a Seed, the worker draws
+// from the next stream derived from it.
+func (s *Solver) newRNG() *frand.RNG {
+	if s.Seed == 0 {
+		return frand.New()
+	}
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], s.Seed)
+	binary.LittleEndian.PutUint64(key[8:], s.streams.Add(1))
+	return frand.NewCustom(key[:], 0, 0)
+}
+
+// isExhausted reports whether the attempt budget of the search has been used up.
+func (sp *searchParams) isExhausted() bool {
+	select {
+	case <-sp.exhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// recordRoutines records the number of workers started for the search sp.
+func (s *Solver) recordRoutines(sp *searchParams, n int) {
+	sp.search.routines.Store(int64(n))
+	s.stats.routines.Store(int64(n))
+}
+
+// finishSearch completes the search sp once awaitResult has returned with err.
+// On failure, it stops the workers and waits for them to flush their counters,
+// so that the returned stats cover every candidate examined.
+func (s *Solver) finishSearch(sp *searchParams, cancel context.CancelFunc, err error) Stats {
+	if err != nil {
+		cancel()
+		sp.workers.Wait()
+	} else {
+		s.complete(sp)
+	}
+	s.logSearch(sp, err)
+	return sp.search.snapshot()
+}
+
+// complete records a successful search in the Solver's stats and in the model of
+// the adaptive routine policy.
+func (s *Solver) complete(sp *searchParams) {
+	sp.search.searches.Add(1)
+	s.stats.searches.Add(1)
+	s.routineModel.observe(sp.preP.BitLen(), sp.search.candidates.Load())
+}
+
+// awaitResult waits for the first result sent by the workers of the search sp.
+// It returns ErrSearchExhausted once the attempt budget has been used up, and the
+// cause of ctx once it is done, which is ErrSearchExhausted if the time budget
+// has expired.
+func awaitResult[T any](ctx context.Context, sp *searchParams, resChan <-chan T) (T, error) {
+	var zero T
+	select {
+	case res := <-resChan:
+		return res, nil
+	case <-sp.exhausted:
+	case <-ctx.Done():
+	}
+	// Prefer a result found just before the search was stopped.
+	select {
+	case res := <-resChan:
+		return res, nil
+	default:
+	}
+	if err := context.Cause(ctx); err != nil {
+		return zero, err
+	}
+	return zero, ErrSearchExhausted
+}
+
+// splitPrime returns a Gaussian prime a + b*i of norm p, given s with s^2 = -1 (mod p),
+// using the configured split method. It reports false if p turns out to be composite.
+func (sp *searchParams) splitPrime(s, p *big.Int) (*comp.GaussianInt, bool) {
+	if sp.split == CornacchiaSplit || sp.split == AutoSplit && !useFastGCD(p, sp.fastGCDBits) {
+		gcd, ok := cornacchiaSplit(s, p)
+		if !ok {
+			sp.observeGCDRejected(p)
+		}
+		return gcd, ok
+	}
+	gcd := computeGaussianGCD(s, p, sp.fastGCDBits)
+	if !isValidGaussianGCD(gcd) {
+		sp.observeGCDRejected(p)
+		return nil, false
+	}
+	return gcd, true
+}
+
+// splitCandidate splits the candidate prime p like splitPrime. A failed split
+// means that p is composite, is counted as a composite retry and returns
+// errSplitFailed.
+func (w *searchWorker) splitCandidate(s, p *big.Int) (*comp.GaussianInt, error) {
+	gcd, ok := w.sp.splitPrime(s, p)
+	if !ok {
+		w.stats.compositeRetries++
+		return nil, errSplitFailed
+	}
+	return gcd, nil
+}
+
+// observeGCDRejected reports a failed split of p to the observer, if any.
+func (sp *searchParams) observeGCDRejected(p *big.Int) {
+	if sp.observer != nil {
+		sp.observer.OnGCDRejected(p)
+	}
+}
+
+// primalityTest returns the configured primality test, or the default
+// Baillie-PSW test if none is set.
+func (s *Solver) primalityTest() func(*big.Int) bool {
+	if s.PrimalityTest != nil {
+		return s.PrimalityTest
+	}
+	return defaultPrimalityTest
+}
+
+// defaultPrimalityTest runs the Baillie-PSW test only.
+func defaultPrimalityTest(p *big.Int) bool {
+	return p.ProbablyPrime(0)
+}
+
+// FermatTest is a cheap base-2 Fermat probable prime test. It is weaker than the
+// default Baillie-PSW test, which is acceptable for the solver: a composite p that
+// passes it is detected later in the search and the candidate is retried.
+func FermatTest(p *big.Int) bool {
+	if p.Cmp(big2) <= 0 {
+		return p.Cmp(big2) == 0
+	}
+	if p.Bit(0) == 0 {
+		return false
+	}
+	pMinus1 := iPool.Get().(*big.Int).Sub(p, big1)
+	defer iPool.Put(pMinus1)
+	opt := iPool.Get().(*big.Int).Exp(big2, pMinus1, p)
+	defer iPool.Put(opt)
+	return opt.Cmp(big1) == 0
+}
+
+// computeSqrtMinusOne computes s with s^2 = -1 (mod p) for a candidate prime
+// p = 1 (mod 4), using a single modular exponentiation s = u^((p-1)/4) with a
+// small quadratic non-residue u. It reports false if p turns out to be composite.
+func computeSqrtMinusOne(p *big.Int) (*big.Int, bool) {
+	u := findQuadraticNonResidue(p)
+	if u == 0 {
+		return nil, false
+	}
+	pow := iPool.Get().(*big.Int).Rsh(p, 2) // (p-1)/4 as p = 1 (mod 4)
+	defer iPool.Put(pow)
+	base := iPool.Get().(*big.Int).SetUint64(u)
+	defer iPool.Put(base)
+	s := new(big.Int).Exp(base, pow, p)
+	if !isSqrtMinusOne(s, p) {
+		return nil, false
+	}
+	return s, true
+}
+
+// findQuadraticNonResidue returns a small quadratic non-residue modulo p for
+// p = 1 (mod 4). If p = 5 (mod 8), 2 is a non-residue. Otherwise the smallest odd
+// prime q with Jacobi(q|p) = -1 is returned; by quadratic reciprocity
+// Jacobi(q|p) = Jacobi(p mod q|q), so only word-sized arithmetic is needed.
+// It returns 0 if p is not 1 (mod 4), has a small factor, or no non-residue is
+// found below qnrSearchBound, each of which means that p is composite in practice.
+func findQuadraticNonResidue(p *big.Int) uint64 {
+	if p.Sign() <= 0 || p.Bits()[0]&3 != 1 {
+		return 0
+	}
+	if p.Bits()[0]&7 == 5 {
+		return 2
+	}
+	for _, q := range oddPrimesBelow(qnrSearchBound) {
+		switch jacobiWord(modWord(p, q), q) {
+		case -1:
+			return q
+		case 0:
+			if p.BitLen() > 64 || p.Uint64() != q {
+				return 0
+			}
+		}
+	}
+	return 0
+}
+
+// jacobiWord computes the Jacobi symbol (a|n) for an odd positive n.
+func jacobiWord(a, n uint64) int {
+	a %= n
+	j := 1
+	for a != 0 {
+		for a&1 == 0 {
+			a >>= 1
+			if r := n & 7; r == 3 || r == 5 {
+				j = -j
+			}
+		}
+		a, n = n, a
+		if a&3 == 3 && n&3 == 3 {
+			j = -j
+		}
+		a %= n
+	}
+	if n == 1 {
+		return j
+	}
+	return 0
+}
+
+// isSqrtMinusOne reports whether s^2 = -1 (mod p). For a prime p this always holds
+// for the s computed from a quadratic non-residue, so a failure proves p composite.
+func isSqrtMinusOne(s, p *big.Int) bool {
+	opt := iPool.Get().(*big.Int).Mul(s, s)
+	defer iPool.Put(opt)
+	opt.Add(opt, big1)
+	opt.Mod(opt, p)
+	return opt.Sign() == 0
+}
@@ -0,0 +1,193 @@
+package lfs
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"lukechampine.com/frand"
+)
+
+func TestJacobiWord(t *testing.T) {
+	for n := uint64(1); n < 200; n += 2 {
+		for a := uint64(0); a < 200; a++ {
+			want := big.Jacobi(new(big.Int).SetUint64(a), new(big.Int).SetUint64(n))
+			if got := jacobiWord(a, n); got != want {
+				t.Fatalf("jacobiWord(%d, %d) = %d, want %d", a, n, got, want)
+			}
+		}
+	}
+}
+
+func TestComputeSqrtMinusOne(t *testing.T) {
+	for _, p := range testPrimesOneModFour(t, 256, 8) {
+		s, ok := computeSqrtMinusOne(p)
+		if !ok {
+			t.Fatalf("computeSqrtMinusOne(%v) failed for a prime", p)
+		}
+		if !isSqrtMinusOne(s, p) {
+			t.Fatalf("computeSqrtMinusOne(%v) = %v, s^2 != -1", p, s)
+		}
+		u := findQuadraticNonResidue(p)
+		if big.Jacobi(new(big.Int).SetUint64(u), p) != -1 {
+			t.Fatalf("findQuadraticNonResidue(%v) = %d is a residue", p, u)
+		}
+	}
+	// A product of two primes = 1 (mod 4) is = 1 (mod 4) with no small factor.
+	composite := new(big.Int).Mul(big.NewInt(1000033), big.NewInt(1000037))
+	if _, ok := computeSqrtMinusOne(composite); ok {
+		t.Errorf("computeSqrtMinusOne(%v) succeeded for a composite", composite)
+	}
+}
+
+func TestCompositeRetries(t *testing.T) {
+	s := NewSolver(WithPrimalityTest(func(*big.Int) bool { return true }))
+	sp := s.newSearchParams(big.NewInt(30), big.NewInt(1<<10))
+	w := newSearchWorker(sp)
+	// 65 = 5*13 has no square root of -1 computed from the non-residue 3.
+	if _, _, _, err := w.testCandidateP(big.NewInt(65)); err != errNoSqrtMinusOne {
+		t.Errorf("testCandidateP(65) error = %v, want %v", err, errNoSqrtMinusOne)
+	}
+	// 6 + i has norm 37 and shares no factor with 65, and 65 - 6^2 is no square.
+	for _, split := range []SplitMethod{CornacchiaSplit, GCDSplit} {
+		sp.split = split
+		if _, err := w.splitCandidate(big.NewInt(6), big.NewInt(65)); err != errSplitFailed {
+			t.Errorf("splitCandidate(6, 65) error = %v with split method %d, want %v", err, split, errSplitFailed)
+		}
+	}
+	if w.stats.compositeRetries != 3 {
+		t.Errorf("compositeRetries = %d, want 3", w.stats.compositeRetries)
+	}
+}
+
+// testPrimesOneModFour generates count random primes p = 1 (mod 4) of the given bit length.
+func testPrimesOneModFour(tb testing.TB, bits, count int) []*big.Int {
+	tb.Helper()
+	var primes []*big.Int
+	for len(primes) < count {
+		p, err := rand.Prime(rand.Reader, bits)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if p.Bit(1) == 0 {
+			primes = append(primes, p)
+		}
+	}
+	return primes
+}
+
+// sqrtMinusOneEuler is the former approach of drawing random even u until the
+// Euler criterion identifies a non-residue, kept for comparison in benchmarks.
+func sqrtMinusOneEuler(p *big.Int) (*big.Int, bool) {
+	pMinus1 := new(big.Int).Sub(p, big1)
+	powU := new(big.Int).Rsh(pMinus1, 1)
+	halfP := new(big.Int).Rsh(p, 1)
+	opt := new(big.Int)
+	for i := 0; i < 10; i++ {
+		u := frand.BigIntn(halfP)
+		u.Lsh(u, 1)
+		opt.Exp(u, powU, p)
+		if opt.Cmp(pMinus1) == 0 {
+			return new(big.Int).Exp(u, powU.Rsh(powU, 1), p), true
+		}
+	}
+	return nil, false
+}
+
+func BenchmarkSqrtMinusOne(b *testing.B) {
+	for _, bits := range []int{1024, 2048} {
+		primes := testPrimesOneModFour(b, bits, 4)
+		b.Run(fmt.Sprintf("jacobi/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				computeSqrtMinusOne(primes[i%len(primes)])
+			}
+		})
+		b.Run(fmt.Sprintf("euler/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sqrtMinusOneEuler(primes[i%len(primes)])
+			}
+		})
+	}
+}
+
+func TestSearchWorkerAllocs(t *testing.T) {
+	n := new(big.Int).Sub(new(big.Int).Lsh(big1, 2048), big.NewInt(12345))
+	s := NewSolver()
+	// Rejecting every candidate in the primality test keeps the worker in its
+	// steady-state loop, which must not allocate.
+	s.PrimalityTest = func(*big.Int) bool { return false }
+	tests := []struct {
+		name string
+		sp   *searchParams
+		pick func(w *searchWorker)
+	}{
+		{
+			name: "small",
+			sp:   s.newSearchParams(new(big.Int).Mul(big.NewInt(30030), big.NewInt(40001)), big.NewInt(1<<30)),
+			pick: func(w *searchWorker) { w.pickCandidateS(big2, big1) },
+		},
+		{
+			name: "large",
+			sp:   s.newSearchParams(new(big.Int).Mul(tinyPrimeProd, n), new(big.Int).Lsh(big1, 35)),
+			pick: func(w *searchWorker) { w.pickCandidateSLarge() },
+		},
+		{
+			name: "fcm",
+			sp:   s.newSearchParams(new(big.Int).Lsh(n, 1), new(big.Int).Lsh(big1, 1024)),
+			pick: func(w *searchWorker) { w.fcmPickCandidate() },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newSearchWorker(tt.sp)
+			// Grow the scratch values before measuring.
+			for i := 0; i < 10; i++ {
+				tt.pick(w)
+			}
+			if allocs := testing.AllocsPerRun(1000, func() { tt.pick(w) }); allocs != 0 {
+				t.Errorf("candidate loop allocates %v times per candidate, want 0", allocs)
+			}
+		})
+	}
+}
+
+func TestSievedSearchBudget(t *testing.T) {
+	n := new(big.Int).Sub(new(big.Int).Lsh(big1, 2048), big.NewInt(12345))
+	sp := NewSolver(WithMaxAttempts(100)).newSearchParams(new(big.Int).Mul(tinyPrimeProd, n), new(big.Int).Lsh(big1, 35))
+	// A broken sieve that marks every candidate makes success impossible.
+	sp.sieve = &candidateSieve{primes: []uint64{1}, residues: []uint64{0}, inverses: []uint64{1}}
+	w := newSearchWorker(sp)
+	if _, _, ok, _ := w.pickCandidateSLargeSieved(); ok || w.stats.candidates != sieveWindowSize {
+		t.Fatalf("pickCandidateSLargeSieved() = %v after %d candidates, want false after one window", ok, w.stats.candidates)
+	}
+	// The worker checks the budget between windows and gives up.
+	sp.workers.Add(1)
+	workerFindS(context.Background(), sp, make(chan searchResult, 1), (*searchWorker).pickCandidateSLargeSieved)
+	if !sp.isExhausted() {
+		t.Error("the worker stopped before exhausting the budget")
+	}
+}
+
+func BenchmarkCandidateLoop(b *testing.B) {
+	n := new(big.Int).Sub(new(big.Int).Lsh(big1, 2048), big.NewInt(12345))
+	for _, bound := range []int{1 << 12, 1 << 16, 1 << 20} {
+		s := NewSolver(WithSieveBound(bound))
+		// Rejecting every survivor isolates the cost of producing it.
+		s.PrimalityTest = func(*big.Int) bool { return false }
+		sp := s.newSearchParams(new(big.Int).Mul(tinyPrimeProd, n), new(big.Int).Lsh(big1, 35))
+		b.Run(fmt.Sprintf("random/%d", bound), func(b *testing.B) {
+			w := newSearchWorker(sp)
+			for i := 0; i < b.N; i++ {
+				w.pickCandidateSLarge()
+			}
+		})
+		b.Run(fmt.Sprintf("sieved/%d", bound), func(b *testing.B) {
+			w := newSearchWorker(sp)
+			for i := 0; i < b.N; i++ {
+				w.pickCandidateSLargeSieved()
+			}
+		})
+	}
+}
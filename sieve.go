@@ -0,0 +1,166 @@
+package lfs
+
+import (
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+const (
+	// defaultSieveBound is the default upper bound of the small primes used by the candidate sieve.
+	defaultSieveBound = 1 << 12
+	// maxSieveBound caps the sieve bound so that products of residues fit in a uint64.
+	maxSieveBound = 1 << 24
+	// sieveWindowSize is the number of candidates sieved at once by SievedSearch.
+	sieveWindowSize = 1 << 12
+)
+
+// sievePrimes caches the odd primes below a sieve bound, keyed by the bound.
+var sievePrimes = sync.Map{}
+
+// candidateSieve rejects candidates with a small prime factor using word-sized
+// modular arithmetic, before the expensive primality test is run.
+type candidateSieve struct {
+	primes   []uint64 // odd primes below the bound
+	residues []uint64 // preP mod primes[i]
+	inverses []uint64 // inverse of preP mod primes[i], or 0 if primes[i] divides preP
+	maxBits  int      // bit length of the largest prime
+}
+
+// newCandidateSieve precomputes the residues of preP modulo the odd primes below bound.
+// It returns nil if the bound is too small for sieving to be useful.
+func newCandidateSieve(preP *big.Int, bound int) *candidateSieve {
+	if bound < 3 {
+		return nil
+	}
+	if bound > maxSieveBound {
+		bound = maxSieveBound
+	}
+	primes := oddPrimesBelow(bound)
+	if len(primes) == 0 {
+		return nil
+	}
+	sv := &candidateSieve{
+		primes:   primes,
+		residues: make([]uint64, len(primes)),
+		inverses: make([]uint64, len(primes)),
+		maxBits:  bits.Len64(primes[len(primes)-1]),
+	}
+	for i, q := range primes {
+		sv.residues[i] = modWord(preP, q)
+		sv.inverses[i] = invertWord(sv.residues[i], q)
+	}
+	return sv
+}
+
+// rejectsMulSubOne reports whether p = preP*k - 1 has a prime factor in the sieve.
+func (sv *candidateSieve) rejectsMulSubOne(k, p *big.Int) bool {
+	if sv == nil || p.BitLen() <= sv.maxBits {
+		return false
+	}
+	for i, q := range sv.primes {
+		if sv.residues[i]*modWord(k, q)%q == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectsSubSquare reports whether p = preP - l^2 has a prime factor in the sieve.
+func (sv *candidateSieve) rejectsSubSquare(l, p *big.Int) bool {
+	if sv == nil || p.BitLen() <= sv.maxBits {
+		return false
+	}
+	for i, q := range sv.primes {
+		lm := modWord(l, q)
+		if lm*lm%q == sv.residues[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// sieveWindow marks composite[j] for every j in [0, len(composite)) for which
+// preP*(k0 + 2j) - 1 has a prime factor in the sieve, and returns the number of
+// marked entries. Each prime is handled with a single modular reduction of k0,
+// after which its multiples are crossed out as in a segmented sieve.
+func (sv *candidateSieve) sieveWindow(k0 *big.Int, composite []bool) int {
+	clear(composite)
+	if sv == nil {
+		return 0
+	}
+	marked := 0
+	size := uint64(len(composite))
+	for i, q := range sv.primes {
+		inv := sv.inverses[i]
+		if inv == 0 {
+			// q divides preP, so preP*k - 1 = -1 (mod q).
+			continue
+		}
+		// preP*(k0 + 2j) = 1 (mod q) <=> j = (preP^-1 - k0) * 2^-1 (mod q).
+		j := (inv + q - modWord(k0, q)) % q * ((q + 1) / 2) % q
+		for ; j < size; j += q {
+			if !composite[j] {
+				composite[j] = true
+				marked++
+			}
+		}
+	}
+	return marked
+}
+
+// invertWord returns the inverse of a modulo the prime q, or 0 if a = 0 (mod q).
+func invertWord(a, q uint64) uint64 {
+	t, newT := int64(0), int64(1)
+	r, newR := int64(q), int64(a%q)
+	for newR != 0 {
+		quo := r / newR
+		t, newT = newT, t-quo*newT
+		r, newR = newR, r-quo*newR
+	}
+	if r != 1 {
+		return 0
+	}
+	if t < 0 {
+		t += int64(q)
+	}
+	return uint64(t)
+}
+
+// modWord returns x mod q for a non-negative x without allocating.
+func modWord(x *big.Int, q uint64) uint64 {
+	if x.IsUint64() {
+		return x.Uint64() % q
+	}
+	var r uint64
+	words := x.Bits()
+	for i := len(words) - 1; i >= 0; i-- {
+		if bits.UintSize == 32 {
+			r = (r<<32 | uint64(words[i])) % q
+		} else {
+			r = bits.Rem64(r, uint64(words[i]), q)
+		}
+	}
+	return r
+}
+
+// oddPrimesBelow returns the odd primes smaller than bound, using a cached
+// sieve of Eratosthenes.
+func oddPrimesBelow(bound int) []uint64 {
+	if cached, ok := sievePrimes.Load(bound); ok {
+		return cached.([]uint64)
+	}
+	composite := make([]bool, bound)
+	var primes []uint64
+	for i := 3; i < bound; i += 2 {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, uint64(i))
+		for j := i * i; j < bound; j += 2 * i {
+			composite[j] = true
+		}
+	}
+	sievePrimes.Store(bound, primes)
+	return primes
+}
@@ -0,0 +1,66 @@
+package lfs
+
+import (
+	"math/big"
+	"testing"
+
+	"lukechampine.com/frand"
+)
+
+func TestCandidateSieve(t *testing.T) {
+	preP := new(big.Int).Mul(tinyPrimeProd, frand.BigIntn(new(big.Int).Lsh(big1, 256)))
+	preP.SetBit(preP, 0, 0)
+	sv := newCandidateSieve(preP, 1<<10)
+	if sv == nil {
+		t.Fatal("newCandidateSieve() = nil")
+	}
+	hasSmallFactor := func(p *big.Int) bool {
+		for _, q := range oddPrimesBelow(1 << 10) {
+			if modWord(p, q) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	limit := new(big.Int).Lsh(big1, 40)
+	p := new(big.Int)
+	for i := 0; i < 2000; i++ {
+		k := frand.BigIntn(limit)
+		p.Mul(preP, k).Sub(p, big1)
+		if got, want := sv.rejectsMulSubOne(k, p), hasSmallFactor(p); got != want {
+			t.Fatalf("rejectsMulSubOne(%v) = %v, want %v", k, got, want)
+		}
+		l := frand.BigIntn(limit)
+		p.Mul(l, l).Sub(preP, p)
+		if got, want := sv.rejectsSubSquare(l, p), hasSmallFactor(p); got != want {
+			t.Fatalf("rejectsSubSquare(%v) = %v, want %v", l, got, want)
+		}
+	}
+	if newCandidateSieve(preP, 2) != nil {
+		t.Error("newCandidateSieve() with bound 2 should disable sieving")
+	}
+}
+
+func TestCandidateSieve_sieveWindow(t *testing.T) {
+	preP := new(big.Int).Mul(tinyPrimeProd, frand.BigIntn(new(big.Int).Lsh(big1, 256)))
+	sv := newCandidateSieve(preP, 1<<10)
+	k0 := frand.BigIntn(new(big.Int).Lsh(big1, 40))
+	k0.Or(k0, big1)
+	window := make([]bool, 512)
+	marked := sv.sieveWindow(k0, window)
+	count := 0
+	k, p := new(big.Int), new(big.Int)
+	for j, composite := range window {
+		k.SetInt64(int64(2*j)).Add(k, k0)
+		p.Mul(preP, k).Sub(p, big1)
+		if want := sv.rejectsMulSubOne(k, p); composite != want {
+			t.Fatalf("sieveWindow() marks k = %v as %v, want %v", k, composite, want)
+		}
+		if composite {
+			count++
+		}
+	}
+	if marked != count {
+		t.Errorf("sieveWindow() = %d, want %d", marked, count)
+	}
+}
@@ -5,13 +5,52 @@
 package lfs
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"math/big"
 	"runtime"
+	"sync/atomic"
+	"time"
 )
 
+// ErrSearchExhausted is returned when a randomized search gives up because its
+// attempt or time budget has been used up.
+var ErrSearchExhausted = errors.New("lfs: search budget exhausted")
+
 // Option defines a functional option for configuring the Solver.
 type Option func(*Solver)
 
+// SearchMode selects how the randomized search draws its candidates.
+type SearchMode int
+
+const (
+	// RandomSearch draws an independent random multiplier for every candidate.
+	RandomSearch SearchMode = iota
+	// SievedSearch starts each worker from a random multiplier k and walks the
+	// arithmetic progression k, k+2, k+4, ..., sieving a whole window of candidates
+	// against the small primes at once before testing the survivors. It applies to
+	// the basic algorithm for large inputs. As sieving is amortized over the window,
+	// it allows much larger sieve bounds (see WithSieveBound, for example 2^20),
+	// which pays off at 2000 bits and above.
+	SievedSearch
+)
+
+// SplitMethod selects how a candidate prime p = a^2 + b^2 is split into a and b,
+// given s with s^2 = -1 (mod p).
+type SplitMethod int
+
+const (
+	// AutoSplit uses CornacchiaSplit below FastGCDThreshold and GCDSplit from it on,
+	// whichever is faster for the size of p.
+	AutoSplit SplitMethod = iota
+	// GCDSplit computes the Gaussian GCD of s + i and p.
+	GCDSplit
+	// CornacchiaSplit runs the Euclidean algorithm on the integers p and s and
+	// stops at the first remainder below sqrt(p), as in Cornacchia's algorithm.
+	CornacchiaSplit
+)
+
 // Solver encapsulates configuration for computing the four-square representation.
 type Solver struct {
 	// FCMThreshold determines when to use the FCM-based algorithm.
@@ -19,15 +58,101 @@ type Solver struct {
 	FCMThreshold *big.Int
 
 	// NumRoutines specifies the number of goroutines to use for parallel randomized search.
+	// Values below one are treated as one.
 	NumRoutines int
+
+	// SieveBound is the upper bound of the small primes used to sieve candidates
+	// before the primality test. A value below 3 disables sieving.
+	SieveBound int
+
+	// PrimalityTest reports whether a candidate p is (probably) prime.
+	// If nil, the Baillie-PSW test of big.Int.ProbablyPrime(0) is used.
+	PrimalityTest func(*big.Int) bool
+
+	// SearchMode selects how the basic algorithm draws candidates for large inputs.
+	SearchMode SearchMode
+
+	// PrecomputeLimit is the largest odd component served from a lazily built table
+	// of precomputed representations instead of a randomized search. Solve consults
+	// the table before the native solver and the FCM algorithm.
+	PrecomputeLimit int
+
+	// FastGCDThreshold is the bit length from which the Gaussian GCD and the Hurwitz
+	// GCRD use a Lehmer-style algorithm instead of the plain Euclidean algorithm of
+	// go-bigcomplex. A value of 0 disables the Lehmer-style algorithms.
+	FastGCDThreshold int
+
+	// SplitMethod selects how candidate primes are split into a sum of two squares.
+	SplitMethod SplitMethod
+
+	// AdaptiveRoutines reduces the number of workers below NumRoutines for inputs
+	// that are too small to benefit from parallel search.
+	AdaptiveRoutines bool
+
+	// HedgingMargin is the distance in bits from FCMThreshold within which Solve races
+	// the basic algorithm against the FCM algorithm. A value of 0 disables hedging.
+	HedgingMargin int
+
+	// MaxAttempts is the number of candidates after which a randomized search gives
+	// up with ErrSearchExhausted. A value of 0 means no limit.
+	MaxAttempts uint64
+
+	// TimeBudget is the duration after which a solve gives up with ErrSearchExhausted.
+	// A value of 0 means no limit.
+	TimeBudget time.Duration
+
+	// Seed, if non-zero, derives the random streams of the search workers from it
+	// instead of the system entropy. A Solver with a single routine then returns the
+	// same results for the same sequence of inputs.
+	Seed uint64
+
+	// ResultCacheSize is the number of representations kept in an LRU cache keyed
+	// on n, so that solving the same value again returns the cached result. A value
+	// of 0 disables the cache.
+	ResultCacheSize int
+
+	// CacheVerifiedOnly restricts the result cache to representations that pass Verify.
+	CacheVerifiedOnly bool
+
+	// Observer, if not nil, receives callbacks at the phases of every solve.
+	Observer Observer
+
+	// Progress, if not nil, is called periodically by the workers of a randomized
+	// search with its progress. It must return quickly.
+	Progress func(Progress)
+
+	// ProgressInterval is the minimum interval between two progress reports of a
+	// search. A value of 0 means 500ms.
+	ProgressInterval time.Duration
+
+	// SelfVerify runs Verify on every result before it is returned and solves the
+	// input again if the result is wrong, up to SelfVerifyRetries times.
+	SelfVerify        bool
+	SelfVerifyRetries int
+
+	// Logger, if not nil, receives debug records of the solve phases and error
+	// records of failed candidate trials. The solver is silent without it.
+	Logger *slog.Logger
+
+	stats        searchStats
+	routineModel routineModel
+	streams      atomic.Uint64 // random streams derived from Seed so far
+	results      resultCache
+
+	// testHookResult, if not nil, replaces the result of every uncached solve.
+	testHookResult func(n *big.Int, fi FourInt) FourInt
 }
 
 // NewSolver creates a new Solver with the provided options.
-// By default, FCMThreshold is set to 2^500 and NumRoutines to the number of available CPUs.
+// By default, FCMThreshold is set to 2^500, NumRoutines to the number of available CPUs,
+// SieveBound to 4096, PrecomputeLimit to 20 and FastGCDThreshold to 256.
 func NewSolver(opts ...Option) *Solver {
 	s := &Solver{
-		FCMThreshold: new(big.Int).Lsh(big1, 500),
-		NumRoutines:  runtime.NumCPU(),
+		FCMThreshold:     new(big.Int).Lsh(big1, 500),
+		NumRoutines:      runtime.NumCPU(),
+		SieveBound:       defaultSieveBound,
+		PrecomputeLimit:  precomputeLmt,
+		FastGCDThreshold: defaultFastGCDThreshold,
 	}
 	for _, opt := range opts {
 		opt(s)
@@ -49,20 +174,286 @@ func WithNumRoutines(n int) Option {
 	}
 }
 
+// WithAdaptiveRoutines enables choosing the number of workers from the bit length
+// of the input and the observed candidates per success, up to NumRoutines. Small
+// inputs are then searched by a single worker. The decision of the most recent
+// search is reported in Stats.Routines.
+func WithAdaptiveRoutines() Option {
+	return func(s *Solver) {
+		s.AdaptiveRoutines = true
+	}
+}
+
+// WithHedging configures Solve to run the basic algorithm and the FCM algorithm
+// concurrently for inputs whose bit length is within margin bits of FCMThreshold,
+// splitting the NumRoutines workers between them. The first representation found
+// is returned and the other algorithm is cancelled, which cuts the tail latency
+// near the threshold, where neither algorithm is reliably faster. A margin of 0
+// disables hedging, and with fewer than two NumRoutines inputs are not hedged.
+func WithHedging(margin int) Option {
+	return func(s *Solver) {
+		s.HedgingMargin = margin
+	}
+}
+
+// WithLogger configures a structured logger for the solver. It receives debug
+// records of the selected paths, the start and stop of search workers, the
+// duration of searches and the growth of the result cache, and error records of
+// candidate trials that failed unexpectedly. A nil logger silences the solver.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Solver) {
+		s.Logger = l
+	}
+}
+
+// WithMaxAttempts configures the number of candidates after which a randomized
+// search gives up with ErrSearchExhausted. A limit of 0 disables the budget.
+func WithMaxAttempts(n uint64) Option {
+	return func(s *Solver) {
+		s.MaxAttempts = n
+	}
+}
+
+// WithProgress configures a function that is called periodically during long
+// randomized searches with the candidates examined so far, the elapsed time and
+// the estimated remaining time. It is called from a search worker, at most once
+// per ProgressInterval per search, and must return quickly. Searches raced by
+// hedging and the inputs of SolveVector report separately.
+func WithProgress(fn func(Progress)) Option {
+	return func(s *Solver) {
+		s.Progress = fn
+	}
+}
+
+// WithProgressInterval configures the minimum interval between two progress
+// reports of a search. A duration of 0 restores the default of 500ms.
+func WithProgressInterval(d time.Duration) Option {
+	return func(s *Solver) {
+		s.ProgressInterval = d
+	}
+}
+
+// WithSeed configures the seed from which the random streams of the search workers
+// are derived, for reproducible results with a single routine. A seed of 0 draws
+// them from the system entropy.
+func WithSeed(seed uint64) Option {
+	return func(s *Solver) {
+		s.Seed = seed
+	}
+}
+
+// WithResultCache configures an LRU cache of the last size representations, keyed
+// on n. Solving a cached value again returns a copy of the cached result without
+// a search, and the hits and misses are counted in Stats. A size of 0 disables
+// the cache.
+func WithResultCache(size int) Option {
+	return func(s *Solver) {
+		s.ResultCacheSize = size
+	}
+}
+
+// WithCacheVerifiedOnly configures the result cache to store only representations
+// that pass Verify.
+func WithCacheVerifiedOnly() Option {
+	return func(s *Solver) {
+		s.CacheVerifiedOnly = true
+	}
+}
+
+// WithObserver configures an Observer that is notified of the start, the selected
+// path, the candidates and the end of every solve, for example an ExpvarObserver.
+// A nil observer disables the callbacks.
+func WithObserver(o Observer) Option {
+	return func(s *Solver) {
+		s.Observer = o
+	}
+}
+
+// WithTimeBudget configures the duration after which a solve gives up with
+// ErrSearchExhausted. A duration of 0 disables the budget.
+func WithTimeBudget(d time.Duration) Option {
+	return func(s *Solver) {
+		s.TimeBudget = d
+	}
+}
+
+// WithSelfVerify configures the solver to run Verify on every result, including
+// cached ones, before returning it. A wrong result is discarded and the input is
+// solved again with fresh candidates, up to retries times, after which
+// ErrVerificationFailed is returned instead of the result. Failures are counted
+// in Stats.VerifyFailures.
+func WithSelfVerify(retries int) Option {
+	return func(s *Solver) {
+		s.SelfVerify = true
+		s.SelfVerifyRetries = retries
+	}
+}
+
+// WithSieveBound configures the upper bound of the small primes used to sieve
+// candidates before the primality test. A bound below 3 disables sieving.
+func WithSieveBound(bound int) Option {
+	return func(s *Solver) {
+		s.SieveBound = bound
+	}
+}
+
+// WithPrimalityTest configures the primality test applied to candidate primes.
+// A nil test restores the default Baillie-PSW test. FermatTest is a cheaper
+// alternative for throughput-oriented use.
+func WithPrimalityTest(test func(*big.Int) bool) Option {
+	return func(s *Solver) {
+		s.PrimalityTest = test
+	}
+}
+
+// WithPrimalityRounds configures the primality test to run the given number of
+// Miller-Rabin rounds with pseudorandom bases in addition to the Baillie-PSW test.
+func WithPrimalityRounds(rounds int) Option {
+	return func(s *Solver) {
+		s.PrimalityTest = func(p *big.Int) bool {
+			return p.ProbablyPrime(rounds)
+		}
+	}
+}
+
+// WithSearchMode configures how the randomized search draws its candidates.
+func WithSearchMode(mode SearchMode) Option {
+	return func(s *Solver) {
+		s.SearchMode = mode
+	}
+}
+
+// WithPrecomputeLimit configures the largest odd component that is looked up in a
+// table of precomputed representations instead of being searched for. The table
+// is built lazily on first use, and limits above 2^20 are capped. Solve consults
+// it before the native solver and the FCM algorithm.
+func WithPrecomputeLimit(limit int) Option {
+	return func(s *Solver) {
+		s.PrecomputeLimit = limit
+	}
+}
+
+// WithFastGCDThreshold configures the bit length from which the Gaussian GCD and the
+// Hurwitz GCRD switch to Lehmer-style algorithms, which replace most multi-precision
+// divisions with word-sized arithmetic. A threshold of 0 disables them.
+func WithFastGCDThreshold(bits int) Option {
+	return func(s *Solver) {
+		s.FastGCDThreshold = bits
+	}
+}
+
+// WithSplitMethod configures how candidate primes are split into a sum of two squares.
+func WithSplitMethod(method SplitMethod) Option {
+	return func(s *Solver) {
+		s.SplitMethod = method
+	}
+}
+
 // Solve computes the Lagrange four-square representation for n.
-// It automatically selects between the basic algorithm and the FCM algorithm.
+// It automatically selects between the basic algorithm and the FCM algorithm,
+// and solves n below 2^128 with the native arithmetic of SolveUint128.
+// If a budget configured with WithMaxAttempts or WithTimeBudget is exhausted,
+// Solve returns an empty FourInt; use SolveWithStats to handle the failure.
 func (s *Solver) Solve(n *big.Int) FourInt {
+	fi, _, _ := s.solve(context.Background(), n, true)
+	return fi
+}
+
+// SolveBasic computes the representation using the basic algorithm.
+// Budgets are handled as in Solve.
+func (s *Solver) SolveBasic(n *big.Int) FourInt {
+	fi, _, _ := s.solve(context.Background(), n, false)
+	return fi
+}
+
+// SolveWithStats computes the Lagrange four-square representation for n like Solve,
+// and returns the counters of this solve only. The search stops once ctx is done,
+// returning the cause of ctx, or once a budget configured with WithMaxAttempts or
+// WithTimeBudget is exhausted, returning ErrSearchExhausted. In both cases, the
+// returned stats cover the candidates examined so far. An n below 2^128 is solved
+// natively and returns empty stats apart from the result cache counters.
+func (s *Solver) SolveWithStats(ctx context.Context, n *big.Int) (FourInt, Stats, error) {
+	return s.solve(ctx, n, true)
+}
+
+// solve computes the representation of n, using the FCM algorithm from the FCM
+// threshold on if fcm is set. Without fcm, the basic algorithm is used even for
+// small n, which SolveUint128 would otherwise handle.
+func (s *Solver) solve(ctx context.Context, n *big.Int, fcm bool) (FourInt, Stats, error) {
+	if s.Observer == nil {
+		return s.solveCached(ctx, n, fcm)
+	}
+	start := time.Now()
+	s.Observer.OnSolveStart(n)
+	fi, stats, err := s.solveCached(ctx, n, fcm)
+	s.Observer.OnSolveEnd(n, stats, time.Since(start), err)
+	return fi, stats, err
+}
+
+// solveCached computes the representation of n as solve does, without notifying
+// the observer of the start and end of the solve.
+func (s *Solver) solveCached(ctx context.Context, n *big.Int, fcm bool) (FourInt, Stats, error) {
+	if n.Sign() < 0 {
+		return FourInt{}, Stats{}, ErrNegativeInput
+	}
 	if n.Sign() == 0 {
 		// Special case: 0 = 0^2 + 0^2 + 0^2 + 0^2
-		return NewFourInt(precomputedHurwitzGCRDs[0].ValInt())
+		s.observePath(n, PathPrecomputed)
+		return NewFourInt(precomputedHurwitzGCRDs[0].ValInt()), Stats{}, nil
+	}
+	var (
+		stats Stats
+		err   error
+	)
+	fi, hit := s.cachedResult(n)
+	if hit {
+		s.observePath(n, PathCached)
+		stats.CacheHits = 1
+	} else {
+		fi, stats, err = s.solveUncached(ctx, n, fcm)
+		if s.ResultCacheSize > 0 {
+			stats.CacheMisses = 1
+		}
+	}
+	if s.SelfVerify && err == nil {
+		fi, stats, err = s.verifyResult(ctx, n, fcm, fi, stats)
 	}
-	if n.Cmp(s.FCMThreshold) < 0 {
-		return s.solveBasic(n)
+	// A cached result is stored again only if it failed verification and was replaced.
+	if err == nil && (!hit || stats.VerifyFailures > 0) {
+		s.cacheResult(n, fi)
 	}
-	return s.solveFCM(n)
+	return fi, stats, err
 }
 
-// SolveBasic computes the representation using the basic algorithm.
-func (s *Solver) SolveBasic(n *big.Int) FourInt {
-	return s.solveBasic(n)
+// solveUncached computes the representation of a positive n as solve does, without
+// consulting the result cache.
+func (s *Solver) solveUncached(ctx context.Context, n *big.Int, fcm bool) (FourInt, Stats, error) {
+	if s.TimeBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, s.TimeBudget, ErrSearchExhausted)
+		defer cancel()
+	}
+	fi, stats, err := s.solvePath(ctx, n, fcm)
+	if err == nil && s.testHookResult != nil {
+		fi = s.testHookResult(n, fi)
+	}
+	return fi, stats, err
+}
+
+// solvePath selects the path for a positive n and computes its representation.
+func (s *Solver) solvePath(ctx context.Context, n *big.Int, fcm bool) (FourInt, Stats, error) {
+	switch {
+	case fcm && s.usesSmallTable(n):
+		return s.solveBasic(ctx, n, s.NumRoutines)
+	case fcm && n.BitLen() <= 128:
+		s.observePath(n, PathNative)
+		return solveNativeBig(n), Stats{}, nil
+	case fcm && s.hedges(n):
+		s.observePath(n, PathHedged)
+		return s.solveHedged(ctx, n)
+	case !fcm || n.Cmp(s.FCMThreshold) < 0:
+		return s.solveBasic(ctx, n, s.NumRoutines)
+	default:
+		return s.solveFCM(ctx, n, s.NumRoutines)
+	}
 }
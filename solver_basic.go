@@ -2,40 +2,62 @@ package lfs
 
 import (
 	"context"
-	"log"
 	"math"
 	"math/big"
 
 	comp "github.com/txaty/go-bigcomplex"
-	"lukechampine.com/frand"
 )
 
-const (
-	randLimitThreshold = 16
-	maxIterFindU       = 10
-)
+const randLimitThreshold = 16
 
 // solveBasic implements the basic Lagrange four‐square solution algorithm.
-func (s *Solver) solveBasic(n *big.Int) FourInt {
+// The randomized search, if one is needed, uses at most routines workers, and the
+// returned stats cover it.
+func (s *Solver) solveBasic(ctx context.Context, n *big.Int, routines int) (FourInt, Stats, error) {
 	// Factor out powers of 2: n = 2^e * nOdd, with nOdd odd.
 	nOdd, e := extractOddComponent(n)
 
-	var hurwitzGCRD *comp.HurwitzInt
+	var (
+		hurwitzGCRD *comp.HurwitzInt
+		stats       Stats
+	)
 	if nOdd.Cmp(bigPrecomputeLmt) <= 0 {
 		// For small nOdd, use a precomputed Hurwitz GCRD.
+		s.observePath(n, PathPrecomputed)
 		hurwitzGCRD = precomputedHurwitzGCRDs[nOdd.Int64()]
+	} else if gcrd, ok := lookupSmallTable(nOdd, s.PrecomputeLimit); ok {
+		// Use the lazily built table of small odd n before spawning any workers.
+		s.observePath(n, PathPrecomputed)
+		hurwitzGCRD = gcrd
 	} else {
 		// Otherwise, use a randomized trail search.
-		var gaussianGCD *comp.GaussianInt
-		if nOdd.BitLen() < randLimitThreshold {
-			gaussianGCD = findGaussianGCDSmall(nOdd, computePrimeProduct(nOdd), s.NumRoutines)
-		} else {
-			gaussianGCD = findGaussianGCDLarge(nOdd, nOdd.BitLen(), s.NumRoutines)
+		s.observePath(n, PathBasic)
+		var (
+			res searchResult
+			err error
+		)
+		res, stats, err = s.searchBasic(ctx, nOdd, routines)
+		if err != nil {
+			return FourInt{}, stats, err
 		}
-		hurwitzGCRD = finalizeHurwitzGCRD(nOdd, gaussianGCD)
+		hurwitzGCRD = finalizeHurwitzGCRD(nOdd, res.gcd, s.FastGCDThreshold)
+	}
+
+	return composeFourInt(e, hurwitzGCRD), stats, nil
+}
+
+// searchBasic runs the randomized search of the basic algorithm for an odd nOdd
+// above the precomputed values, with at most routines workers.
+func (s *Solver) searchBasic(ctx context.Context, nOdd *big.Int, routines int) (searchResult, Stats, error) {
+	if nOdd.BitLen() < randLimitThreshold {
+		return s.findGaussianGCDSmall(ctx, nOdd, computePrimeProduct(nOdd), routines)
 	}
+	return s.findGaussianGCDLarge(ctx, nOdd, nOdd.BitLen(), routines)
+}
 
-	// Adjust the solution using (1+i)^e.
+// composeFourInt adjusts the Hurwitz GCRD of the odd component using (1+i)^e
+// and returns the resulting four-square representation.
+func composeFourInt(e int, hurwitzGCRD *comp.HurwitzInt) FourInt {
 	gi := computeGaussianOnePlusIPower(e)
 	hurwitzProd := comp.NewHurwitzInt(gi.R, gi.I, big0, big0, false)
 	hurwitzProd.Prod(hurwitzProd, hurwitzGCRD)
@@ -99,42 +121,61 @@ func computePrimeProduct(n *big.Int) *big.Int {
 }
 
 // findGaussianGCDSmall performs random search for a valid Gaussian GCD for small nOdd.
-func findGaussianGCDSmall(n, primeProd *big.Int, numRoutines int) *comp.GaussianInt {
-	preP := iPool.Get().(*big.Int).Mul(primeProd, n)
-	defer iPool.Put(preP)
-	ctx, cancel := context.WithCancel(context.Background())
+// Worker i draws the multipliers k = 2*numRoutines*r + 2*i + 1, so that the workers
+// partition the odd multipliers below the random limit.
+func (s *Solver) findGaussianGCDSmall(ctx context.Context, n, primeProd *big.Int, routines int) (searchResult, Stats, error) {
+	// Values shared with the workers are not pooled, as workers may still read
+	// them after the result has been returned.
+	preP := new(big.Int).Mul(primeProd, n)
+	numRoutines := s.numRoutines(preP.BitLen(), routines)
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	resChan := make(chan *comp.GaussianInt)
+	// The channel is buffered so that a result found before the receive below is not dropped.
+	resChan := make(chan searchResult, 1)
 	randLimit := computeInitialRandLimit(n)
 	randLimit.Rsh(randLimit, 1)
 	randLimit.Div(randLimit, big.NewInt(int64(numRoutines)))
-
-	mul := iPool.Get().(*big.Int).SetInt64(int64(2 * numRoutines))
-	defer iPool.Put(mul)
-	var offsets []*big.Int
-	for i := 0; i <= numRoutines; i++ {
-		offsets = append(offsets, big.NewInt(int64(2*i+1)))
+	if randLimit.Sign() == 0 {
+		randLimit.SetInt64(1)
 	}
-	for _, off := range offsets {
-		go workerFindS(ctx, mul, off, randLimit, preP, resChan)
+	sp := s.newSearchParams(preP, randLimit)
+
+	s.recordRoutines(sp, numRoutines)
+	mul := big.NewInt(int64(2 * numRoutines))
+	for i := 0; i < numRoutines; i++ {
+		sp.workers.Add(1)
+		offset := big.NewInt(int64(2*i + 1))
+		go workerFindS(ctx, sp, resChan, func(w *searchWorker) (*big.Int, *big.Int, bool, error) {
+			return w.pickCandidateS(mul, offset)
+		})
 	}
-	return <-resChan
+	res, err := awaitResult(ctx, sp, resChan)
+	return res, s.finishSearch(sp, cancel, err), err
 }
 
 // findGaussianGCDLarge performs random search for a valid Gaussian GCD for large nOdd.
-func findGaussianGCDLarge(n *big.Int, bitLen, numRoutines int) *comp.GaussianInt {
-	ctx, cancel := context.WithCancel(context.Background())
+func (s *Solver) findGaussianGCDLarge(ctx context.Context, n *big.Int, bitLen, routines int) (searchResult, Stats, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	resChan := make(chan *comp.GaussianInt)
+	resChan := make(chan searchResult, 1)
 	bl := computeRandBitLength(bitLen)
-	preP := iPool.Get().(*big.Int).Mul(tinyPrimeProd, n)
-	defer iPool.Put(preP)
-	randLimit := iPool.Get().(*big.Int).Lsh(big1, uint(bl))
-	defer iPool.Put(randLimit)
+	// Values shared with the workers are not pooled, as workers may still read
+	// them after the result has been returned.
+	preP := new(big.Int).Mul(tinyPrimeProd, n)
+	randLimit := new(big.Int).Lsh(big1, uint(bl))
+	sp := s.newSearchParams(preP, randLimit)
+	pick := (*searchWorker).pickCandidateSLarge
+	if s.SearchMode == SievedSearch {
+		pick = (*searchWorker).pickCandidateSLargeSieved
+	}
+	numRoutines := s.numRoutines(preP.BitLen(), routines)
+	s.recordRoutines(sp, numRoutines)
 	for i := 0; i < numRoutines; i++ {
-		go workerFindSLarge(ctx, randLimit, preP, resChan)
+		sp.workers.Add(1)
+		go workerFindS(ctx, sp, resChan, pick)
 	}
-	return <-resChan
+	res, err := awaitResult(ctx, sp, resChan)
+	return res, s.finishSearch(sp, cancel, err), err
 }
 
 // computeInitialRandLimit computes an initial random limit for candidate generation.
@@ -156,26 +197,37 @@ func computeRandBitLength(bitLen int) int {
 	return int(math.Round(lenF))
 }
 
-// workerFindS is a goroutine that repeatedly searches for a valid candidate.
-func workerFindS(ctx context.Context, mul, offset, randLimit, preP *big.Int, resChan chan<- *comp.GaussianInt) {
+// workerFindS is a goroutine that repeatedly searches for a valid candidate of
+// the basic algorithm, drawing the candidates with pick.
+func workerFindS(ctx context.Context, sp *searchParams, resChan chan<- searchResult, pick func(w *searchWorker) (s, p *big.Int, ok bool, err error)) {
+	w := newSearchWorker(sp)
+	defer sp.workers.Done()
+	defer w.logStop()
+	defer w.flushStats()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			s, p, ok, err := pickCandidateS(mul, offset, randLimit, preP)
+			if w.budgetExceeded() {
+				return
+			}
+			s, p, ok, err := pick(w)
 			if err != nil {
-				log.Panic(err)
+				w.logCompositeCandidate(err)
+				continue
 			}
 			if !ok {
 				continue
 			}
-			gcd := computeGaussianGCD(s, p)
-			if !isValidGaussianGCD(gcd) {
+			gcd, err := w.splitCandidate(s, p)
+			if err != nil {
+				w.logCompositeCandidate(err)
 				continue
 			}
+			w.flushStats()
 			select {
-			case resChan <- gcd:
+			case resChan <- searchResult{gcd: gcd, p: p, s: s}:
 				return
 			default:
 				return
@@ -184,87 +236,107 @@ func workerFindS(ctx context.Context, mul, offset, randLimit, preP *big.Int, res
 	}
 }
 
-// pickCandidateS generates candidate s and p for workerFindS.
-func pickCandidateS(mul, offset, randLimit, preP *big.Int) (*big.Int, *big.Int, bool, error) {
-	k := frand.BigIntn(randLimit)
-	k.Mul(k, mul)
-	k.Add(k, offset)
-	return computeCandidateSP(k, preP)
+// pickCandidateS generates candidate s and p for small nOdd.
+func (w *searchWorker) pickCandidateS(mul, offset *big.Int) (*big.Int, *big.Int, bool, error) {
+	w.randIntn(w.k, w.sp.randLimit)
+	w.k.Mul(w.k, mul)
+	w.k.Add(w.k, offset)
+	return w.computeCandidateSP()
 }
 
-// workerFindSLarge is the worker routine for large nOdd.
-func workerFindSLarge(ctx context.Context, randLimit, preP *big.Int, resChan chan<- *comp.GaussianInt) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			s, p, ok, err := pickCandidateSLarge(randLimit, preP)
-			if err != nil {
-				log.Panic(err)
-			}
-			if !ok {
-				continue
-			}
-			gcd := computeGaussianGCD(s, p)
-			if !isValidGaussianGCD(gcd) {
-				continue
-			}
-			select {
-			case resChan <- gcd:
-				return
-			default:
-				return
-			}
+// pickCandidateSLargeSieved tests the next candidate of the worker's sequential walk.
+// The worker starts from a random odd k and walks k, k+2, k+4, ..., sieving a whole
+// window of candidates preP*k - 1 against the small primes at once, and only the
+// survivors are tested for primality. It reports no candidate at the end of a window,
+// so that the worker checks for cancellation and its budget before the next one.
+func (w *searchWorker) pickCandidateSLargeSieved() (*big.Int, *big.Int, bool, error) {
+	if w.windowPos >= len(w.window) {
+		w.nextSieveWindow()
+	}
+	for w.windowPos < len(w.window) {
+		j := w.windowPos
+		w.windowPos++
+		w.stats.candidates++
+		w.observeCandidate()
+		if w.window[j] {
+			w.stats.sieved++
+			continue
 		}
+		w.k.SetUint64(uint64(2 * j))
+		w.k.Add(w.k, w.k0)
+		p := w.p.Mul(w.sp.preP, w.k)
+		p.Sub(p, big1)
+		return w.testCandidateP(p)
 	}
+	return nil, nil, false, nil
+}
+
+// nextSieveWindow advances the sequential walk to the next window, starting from a
+// random odd k on the first call, and sieves it.
+func (w *searchWorker) nextSieveWindow() {
+	if len(w.window) == 0 {
+		if w.k0 == nil {
+			w.k0 = new(big.Int)
+		}
+		w.randIntn(w.k0, w.sp.randLimit)
+		w.k0.Or(w.k0, big1)
+		if cap(w.window) < sieveWindowSize {
+			w.window = make([]bool, sieveWindowSize)
+		}
+		w.window = w.window[:sieveWindowSize]
+	} else {
+		w.opt.SetUint64(uint64(2 * len(w.window)))
+		w.k0.Add(w.k0, w.opt)
+	}
+	w.windowPos = 0
+	w.sp.sieve.sieveWindow(w.k0, w.window)
 }
 
 // pickCandidateSLarge generates candidate s and p for large nOdd.
-func pickCandidateSLarge(randLimit, preP *big.Int) (*big.Int, *big.Int, bool, error) {
-	k := frand.BigIntn(randLimit)
-	k.Or(k, big1)
-	return computeCandidateSP(k, preP)
+func (w *searchWorker) pickCandidateSLarge() (*big.Int, *big.Int, bool, error) {
+	w.randIntn(w.k, w.sp.randLimit)
+	w.k.Or(w.k, big1)
+	return w.computeCandidateSP()
 }
 
-// computeCandidateSP computes candidate s and p given k and preP.
-func computeCandidateSP(k, preP *big.Int) (*big.Int, *big.Int, bool, error) {
-	p := iPool.Get().(*big.Int).Mul(preP, k)
-	defer iPool.Put(p)
+// computeCandidateSP computes candidate s and p = preP*k - 1 from the worker's k.
+// Candidates with a small prime factor are rejected by the sieve before the primality test.
+// Only candidates passing the primality test allocate.
+func (w *searchWorker) computeCandidateSP() (*big.Int, *big.Int, bool, error) {
+	w.stats.candidates++
+	w.observeCandidate()
+	p := w.p.Mul(w.sp.preP, w.k)
 	p.Sub(p, big1)
-	if !p.ProbablyPrime(0) {
+	if w.sp.sieve.rejectsMulSubOne(w.k, p) {
+		w.stats.sieved++
 		return nil, nil, false, nil
 	}
-	pMinus1 := iPool.Get().(*big.Int).Sub(p, big1)
-	defer iPool.Put(pMinus1)
-	powU := iPool.Get().(*big.Int).Rsh(pMinus1, 1)
-	defer iPool.Put(powU)
-	halfP := iPool.Get().(*big.Int).Rsh(p, 1)
-	defer iPool.Put(halfP)
-	opt := iPool.Get().(*big.Int)
-	defer iPool.Put(opt)
-	u := iPool.Get().(*big.Int)
-	defer iPool.Put(u)
-	found := false
-	for i := 0; i < maxIterFindU; i++ {
-		u = frand.BigIntn(halfP)
-		u.Lsh(u, 1)
-		opt.Exp(u, powU, p)
-		if opt.Cmp(pMinus1) == 0 {
-			found = true
-			break
-		}
-	}
-	if !found {
+	return w.testCandidateP(p)
+}
+
+// testCandidateP runs the primality test on a candidate p that survived sieving
+// and computes s with s^2 = -1 (mod p) if it passes. It returns errNoSqrtMinusOne
+// if p passes the test but turns out to be composite.
+func (w *searchWorker) testCandidateP(p *big.Int) (*big.Int, *big.Int, bool, error) {
+	if !w.sp.isPrime(p) {
 		return nil, nil, false, nil
 	}
-	powU.Rsh(powU, 1)
-	s := new(big.Int).Exp(u, powU, p)
+	w.stats.primes++
+	s, ok := w.sqrtMinusOne(p)
+	if !ok {
+		w.stats.compositeRetries++
+		return nil, nil, false, errNoSqrtMinusOne
+	}
+	w.observePrime(p)
 	return s, new(big.Int).Set(p), true, nil
 }
 
 // computeGaussianGCD computes the Gaussian GCD of (s+i) and p.
-func computeGaussianGCD(s, p *big.Int) *comp.GaussianInt {
+// The Lehmer-style GCD is used if fastBits is positive and p has at least fastBits bits.
+func computeGaussianGCD(s, p *big.Int, fastBits int) *comp.GaussianInt {
+	if useFastGCD(p, fastBits) {
+		return gaussianGCDLehmer(s, big1, p, big0)
+	}
 	gaussS := giPool.Get().(*comp.GaussianInt).Update(s, big1)
 	defer giPool.Put(gaussS)
 	gaussP := giPool.Get().(*comp.GaussianInt).Update(p, big0)
@@ -297,7 +369,14 @@ func isValidGaussianGCD(gcd *comp.GaussianInt) bool {
 }
 
 // finalizeHurwitzGCRD computes the Hurwitz GCRD of (gcd + j) and n.
-func finalizeHurwitzGCRD(n *big.Int, gcd *comp.GaussianInt) *comp.HurwitzInt {
+// The Lehmer-style GCRD is used if fastBits is positive and n has at least fastBits bits.
+func finalizeHurwitzGCRD(n *big.Int, gcd *comp.GaussianInt, fastBits int) *comp.HurwitzInt {
+	if useFastGCD(n, fastBits) {
+		return hurwitzGCRDLehmer(
+			[4]*big.Int{new(big.Int).Lsh(gcd.R, 1), new(big.Int).Lsh(gcd.I, 1), big.NewInt(2), new(big.Int)},
+			[4]*big.Int{new(big.Int).Lsh(n, 1), new(big.Int), new(big.Int), new(big.Int)},
+		)
+	}
 	hurwitzCandidate := hiPool.Get().(*comp.HurwitzInt).Update(gcd.R, gcd.I, big1, big0, false)
 	defer hiPool.Put(hurwitzCandidate)
 	hurwitzN := hiPool.Get().(*comp.HurwitzInt).Update(n, big0, big0, big0, false)
@@ -5,40 +5,41 @@ import (
 	"math/big"
 
 	comp "github.com/txaty/go-bigcomplex"
-	"lukechampine.com/frand"
 )
 
 // solveFCM implements the FCM algorithm for very large n.
-func (s *Solver) solveFCM(n *big.Int) FourInt {
-	// For n below the FCM threshold, fallback to the basic method.
-	if n.Cmp(s.FCMThreshold) < 0 {
-		return s.solveBasic(n)
-	}
+// The randomized search uses at most routines workers, and the returned stats cover it.
+func (s *Solver) solveFCM(ctx context.Context, n *big.Int, routines int) (FourInt, Stats, error) {
+	s.observePath(n, PathFCM)
 	nOdd, e := extractOddComponent(n)
-	gcd, l := fcmRandTrail(nOdd, s.NumRoutines)
-	hurwitzGCRD := fcmFinalizeHurwitzGCRD(nOdd, l, gcd)
-	gi := computeGaussianOnePlusIPower(e)
-	hurwitzProd := comp.NewHurwitzInt(gi.R, gi.I, big0, big0, false)
-	hurwitzProd.Prod(hurwitzProd, hurwitzGCRD)
-	w1, w2, w3, w4 := hurwitzProd.ValInt()
-	return NewFourInt(w1, w2, w3, w4)
+	res, stats, err := s.fcmRandTrail(ctx, nOdd, routines)
+	if err != nil {
+		return FourInt{}, stats, err
+	}
+	hurwitzGCRD := fcmFinalizeHurwitzGCRD(nOdd, res.l, res.gcd, s.FastGCDThreshold)
+	return composeFourInt(e, hurwitzGCRD), stats, nil
 }
 
 // fcmRandTrail performs a random search tailored for the FCM algorithm.
-// It returns a Gaussian GCD along with the candidate l.
-func fcmRandTrail(nOdd *big.Int, numRoutines int) (*comp.GaussianInt, *big.Int) {
-	preP := iPool.Get().(*big.Int).Lsh(nOdd, 1) // preP = 2 * nOdd
-	defer iPool.Put(preP)
-	ctx, cancel := context.WithCancel(context.Background())
+// It returns a Gaussian GCD along with the candidate l and the witnesses.
+func (s *Solver) fcmRandTrail(ctx context.Context, nOdd *big.Int, routines int) (searchResult, Stats, error) {
+	// Values shared with the workers are not pooled, as workers may still read
+	// them after the result has been returned.
+	preP := new(big.Int).Lsh(nOdd, 1) // preP = 2 * nOdd
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	resChan := make(chan fcmFindResult)
-	randLimit := iPool.Get().(*big.Int).Lsh(big1, fcmComputeRandBitLen(preP))
-	defer iPool.Put(randLimit)
+	resChan := make(chan searchResult, 1)
+	randLimit := new(big.Int).Lsh(big1, fcmComputeRandBitLen(preP))
+	sp := s.newSearchParams(preP, randLimit)
+	sp.useFCMDensity()
+	numRoutines := s.numRoutines(preP.BitLen(), routines)
+	s.recordRoutines(sp, numRoutines)
 	for i := 0; i < numRoutines; i++ {
-		go fcmWorkerFindS(ctx, randLimit, preP, resChan)
+		sp.workers.Add(1)
+		go fcmWorkerFindS(ctx, sp, resChan)
 	}
-	res := <-resChan
-	return res.gcd, res.l
+	res, err := awaitResult(ctx, sp, resChan)
+	return res, s.finishSearch(sp, cancel, err), err
 }
 
 // fcmComputeRandBitLen computes a bit length for random candidate generation in FCM.
@@ -51,28 +52,36 @@ func fcmComputeRandBitLen(n *big.Int) uint {
 	return ret
 }
 
-type fcmFindResult struct {
-	gcd *comp.GaussianInt
-	l   *big.Int
-}
-
 // fcmWorkerFindS repeatedly searches for a valid candidate in the FCM algorithm.
-func fcmWorkerFindS(ctx context.Context, randLimit, preP *big.Int, resChan chan<- fcmFindResult) {
+func fcmWorkerFindS(ctx context.Context, sp *searchParams, resChan chan<- searchResult) {
+	w := newSearchWorker(sp)
+	defer sp.workers.Done()
+	defer w.logStop()
+	defer w.flushStats()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			s, p, l, ok := fcmPickCandidate(randLimit, preP)
+			if w.budgetExceeded() {
+				return
+			}
+			s, p, l, ok, err := w.fcmPickCandidate()
+			if err != nil {
+				w.logCompositeCandidate(err)
+				continue
+			}
 			if !ok {
 				continue
 			}
-			gcd := computeGaussianGCD(s, p)
-			if !isValidGaussianGCD(gcd) {
+			gcd, err := w.splitCandidate(s, p)
+			if err != nil {
+				w.logCompositeCandidate(err)
 				continue
 			}
+			w.flushStats()
 			select {
-			case resChan <- fcmFindResult{gcd: gcd, l: l}:
+			case resChan <- searchResult{gcd: gcd, p: p, s: s, l: l}:
 				return
 			default:
 				return
@@ -81,50 +90,47 @@ func fcmWorkerFindS(ctx context.Context, randLimit, preP *big.Int, resChan chan<
 	}
 }
 
-// fcmPickCandidate generates a candidate for the FCM algorithm.
-func fcmPickCandidate(randLimit, preP *big.Int) (s, p, l *big.Int, found bool) {
-	l = frand.BigIntn(randLimit)
+// fcmPickCandidate generates a candidate p = preP - l^2 for the FCM algorithm.
+// Candidates with a small prime factor are rejected by the sieve before the primality test.
+// Only candidates passing the primality test allocate. It returns errNoSqrtMinusOne
+// if p passes the test but turns out to be composite.
+func (w *searchWorker) fcmPickCandidate() (s, p, l *big.Int, found bool, err error) {
+	w.stats.candidates++
+	w.observeCandidate()
+	l = w.randIntn(w.k, w.sp.randLimit)
 	l.Lsh(l, 1)
 	l.Add(l, big1) // ensure l is odd
-	lSq := iPool.Get().(*big.Int).Mul(l, l)
-	defer iPool.Put(lSq)
-	p = new(big.Int).Sub(preP, lSq)
+	lSq := w.opt.Mul(l, l)
+	p = w.p.Sub(w.sp.preP, lSq)
 	if p.Sign() <= 0 {
-		return nil, nil, nil, false
+		return nil, nil, nil, false, nil
 	}
-	if !p.ProbablyPrime(0) {
-		return nil, nil, nil, false
+	if w.sp.sieve.rejectsSubSquare(l, p) {
+		w.stats.sieved++
+		return nil, nil, nil, false, nil
 	}
-	pMinus1 := iPool.Get().(*big.Int).Sub(p, big1)
-	defer iPool.Put(pMinus1)
-	powU := iPool.Get().(*big.Int).Rsh(pMinus1, 1)
-	defer iPool.Put(powU)
-	halfP := iPool.Get().(*big.Int).Rsh(p, 1)
-	defer iPool.Put(halfP)
-	u := iPool.Get().(*big.Int)
-	defer iPool.Put(u)
-	opt := iPool.Get().(*big.Int)
-	defer iPool.Put(opt)
-	valid := false
-	for i := 0; i < maxIterFindU; i++ {
-		u = frand.BigIntn(halfP)
-		u.Lsh(u, 1)
-		opt.Exp(u, powU, p)
-		if opt.Cmp(pMinus1) == 0 {
-			valid = true
-			break
-		}
+	if !w.sp.isPrime(p) {
+		return nil, nil, nil, false, nil
 	}
-	if !valid {
-		return nil, nil, nil, false
+	w.stats.primes++
+	s, ok := w.sqrtMinusOne(p)
+	if !ok {
+		w.stats.compositeRetries++
+		return nil, nil, nil, false, errNoSqrtMinusOne
 	}
-	powU.Rsh(powU, 1)
-	s = new(big.Int).Exp(u, powU, p)
-	return s, new(big.Int).Set(p), l, true
+	w.observePrime(p)
+	return s, new(big.Int).Set(p), new(big.Int).Set(l), true, nil
 }
 
-// fcmFinalizeHurwitzGCRD computes the Hurwitz GCRD for the FCM algorithm.
-func fcmFinalizeHurwitzGCRD(n, l *big.Int, gcd *comp.GaussianInt) *comp.HurwitzInt {
+// fcmFinalizeHurwitzGCRD computes the Hurwitz GCRD of (gcd + l*j) and n for the FCM algorithm.
+// The Lehmer-style GCRD is used if fastBits is positive and n has at least fastBits bits.
+func fcmFinalizeHurwitzGCRD(n, l *big.Int, gcd *comp.GaussianInt, fastBits int) *comp.HurwitzInt {
+	if useFastGCD(n, fastBits) {
+		return hurwitzGCRDLehmer(
+			[4]*big.Int{new(big.Int).Lsh(gcd.R, 1), new(big.Int).Lsh(gcd.I, 1), new(big.Int).Lsh(l, 1), new(big.Int)},
+			[4]*big.Int{new(big.Int).Lsh(n, 1), new(big.Int), new(big.Int), new(big.Int)},
+		)
+	}
 	hurwitzCandidate := hiPool.Get().(*comp.HurwitzInt).Update(gcd.R, gcd.I, l, big0, false)
 	defer hiPool.Put(hurwitzCandidate)
 	hurwitzN := hiPool.Get().(*comp.HurwitzInt).Update(n, big0, big0, big0, false)
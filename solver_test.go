@@ -2,10 +2,14 @@
 package lfs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math/big"
 	"reflect"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestNewSolver(t *testing.T) {
@@ -175,3 +179,424 @@ func TestWithNumRoutines(t *testing.T) {
 		t.Errorf("WithNumRoutines() did not set NumRoutines correctly: got %d, want %d", s.NumRoutines, 8)
 	}
 }
+
+func TestSolver_SolveVector(t *testing.T) {
+	ns := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(4),
+		big.NewInt(12345),
+		new(big.Int).Lsh(big.NewInt(12345), 7),
+		new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(189)),
+		new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(357)),
+		new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 600), big.NewInt(7)),
+	}
+	s := NewSolver(WithNumRoutines(4))
+	got, err := s.SolveVector(context.Background(), ns)
+	if err != nil {
+		t.Fatalf("SolveVector() error = %v", err)
+	}
+	if len(got) != len(ns) {
+		t.Fatalf("SolveVector() returned %d results, want %d", len(got), len(ns))
+	}
+	for i, n := range ns {
+		if !Verify(n, got[i]) {
+			t.Errorf("SolveVector() result %d = %v does not verify for %v", i, got[i], n)
+		}
+	}
+
+	if _, err := s.SolveVector(context.Background(), []*big.Int{big.NewInt(-1)}); !errors.Is(err, ErrNegativeInput) {
+		t.Errorf("SolveVector() error = %v, want %v", err, ErrNegativeInput)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.SolveVector(ctx, ns); !errors.Is(err, context.Canceled) {
+		t.Errorf("SolveVector() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestWithPrimalityTest(t *testing.T) {
+	n := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(189))
+	tests := []struct {
+		name          string
+		opt           Option
+		wantComposite bool
+	}{
+		{name: "fermat", opt: WithPrimalityTest(FermatTest)},
+		{name: "extra rounds", opt: WithPrimalityRounds(4)},
+		{
+			// Accepting every candidate forces composites through the search,
+			// which must detect and retry them.
+			name:          "always prime",
+			opt:           WithPrimalityTest(func(*big.Int) bool { return true }),
+			wantComposite: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A single seeded worker makes the sequence of candidates, and thereby
+			// the composites among them, reproducible.
+			s := NewSolver(tt.opt, WithNumRoutines(1), WithSieveBound(0), WithSeed(1))
+			got := s.Solve(n)
+			if !Verify(n, got) {
+				t.Fatalf("Solve() = %v does not verify for %v", got, n)
+			}
+			stats := s.Stats()
+			if stats.Candidates == 0 || stats.Primes == 0 {
+				t.Errorf("Stats() = %+v, want non-zero candidates and primes", stats)
+			}
+			if tt.wantComposite && stats.CompositeRetries == 0 {
+				t.Errorf("Stats().CompositeRetries = 0, want > 0")
+			}
+			s.ResetStats()
+			if s.Stats() != (Stats{}) {
+				t.Errorf("ResetStats() left %+v", s.Stats())
+			}
+		})
+	}
+}
+
+func BenchmarkSolver_Solve(b *testing.B) {
+	for _, bits := range []uint{256, 1024, 2048} {
+		n := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits), big.NewInt(12345))
+		b.Run(fmt.Sprintf("%d", bits), func(b *testing.B) {
+			s := NewSolver()
+			for i := 0; i < b.N; i++ {
+				s.Solve(n)
+			}
+		})
+	}
+}
+
+func TestWithSearchMode(t *testing.T) {
+	ns := []*big.Int{
+		new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(189)),
+		new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 384), big.NewInt(317)),
+	}
+	s := NewSolver(WithSearchMode(SievedSearch), WithNumRoutines(2))
+	for _, n := range ns {
+		if got := s.Solve(n); !Verify(n, got) {
+			t.Errorf("Solve() = %v does not verify for %v", got, n)
+		}
+	}
+	got, err := s.SolveVector(context.Background(), ns)
+	if err != nil {
+		t.Fatalf("SolveVector() error = %v", err)
+	}
+	for i, n := range ns {
+		if !Verify(n, got[i]) {
+			t.Errorf("SolveVector() result %v does not verify for %v", got[i], n)
+		}
+	}
+}
+
+func TestWithSplitMethod(t *testing.T) {
+	ns := []*big.Int{
+		big.NewInt(123456789),
+		new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(189)),
+	}
+	for _, method := range []SplitMethod{AutoSplit, GCDSplit, CornacchiaSplit} {
+		t.Run(fmt.Sprintf("method %d", method), func(t *testing.T) {
+			s := NewSolver(WithSplitMethod(method), WithNumRoutines(2))
+			for _, n := range ns {
+				// Solve would handle n below 2^128 natively, without splitting a prime.
+				if got := s.SolveBasic(n); !Verify(n, got) {
+					t.Errorf("SolveBasic() = %v does not verify for %v", got, n)
+				}
+				got, _, err := s.solveFCM(context.Background(), n, s.NumRoutines)
+				if err != nil || !Verify(n, got) {
+					t.Errorf("solveFCM() = %v, %v does not verify for %v", got, err, n)
+				}
+			}
+		})
+	}
+}
+
+func TestWithAdaptiveRoutines(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		n            *big.Int
+		wantRoutines int
+	}{
+		{
+			name:         "small input",
+			opts:         []Option{WithNumRoutines(8), WithAdaptiveRoutines()},
+			n:            big.NewInt(1<<40 + 15),
+			wantRoutines: 1,
+		},
+		{
+			name:         "large input",
+			opts:         []Option{WithNumRoutines(8), WithAdaptiveRoutines()},
+			n:            new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 1024), big.NewInt(189)),
+			wantRoutines: 8,
+		},
+		{
+			name:         "no routines",
+			opts:         []Option{WithNumRoutines(0)},
+			n:            big.NewInt(1<<40 + 15),
+			wantRoutines: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSolver(tt.opts...)
+			// Solve handles small inputs natively, without a search.
+			if got := s.SolveBasic(tt.n); !Verify(tt.n, got) {
+				t.Fatalf("SolveBasic() = %v does not verify for %v", got, tt.n)
+			}
+			stats := s.Stats()
+			if stats.Routines != tt.wantRoutines {
+				t.Errorf("Stats().Routines = %d, want %d", stats.Routines, tt.wantRoutines)
+			}
+			if stats.Searches != 1 {
+				t.Errorf("Stats().Searches = %d, want 1", stats.Searches)
+			}
+		})
+	}
+}
+
+func TestSolver_SolveWithStats(t *testing.T) {
+	never := WithPrimalityTest(func(*big.Int) bool { return false })
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(189))
+	tests := []struct {
+		name    string
+		opts    []Option
+		ctx     context.Context
+		n       *big.Int
+		basic   bool // search small n with the basic algorithm instead of natively
+		wantErr error
+	}{
+		{name: "success", ctx: context.Background(), n: n},
+		{name: "max attempts", opts: []Option{never, WithMaxAttempts(1000)}, ctx: context.Background(), n: n, wantErr: ErrSearchExhausted},
+		{name: "max attempts small", opts: []Option{never, WithMaxAttempts(100)}, ctx: context.Background(), n: big.NewInt(12345), basic: true, wantErr: ErrSearchExhausted},
+		{name: "native", opts: []Option{never, WithMaxAttempts(100)}, ctx: context.Background(), n: big.NewInt(12345)},
+		{name: "max attempts fcm", opts: []Option{never, WithMaxAttempts(1000), WithFCMThreshold(big.NewInt(1))}, ctx: context.Background(), n: n, wantErr: ErrSearchExhausted},
+		{name: "time budget", opts: []Option{never, WithTimeBudget(20 * time.Millisecond)}, ctx: context.Background(), n: n, wantErr: ErrSearchExhausted},
+		{name: "cancelled", opts: []Option{never}, ctx: cancelled, n: n, wantErr: context.Canceled},
+		{name: "negative", ctx: context.Background(), n: big.NewInt(-1), wantErr: ErrNegativeInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSolver(append(tt.opts, WithNumRoutines(2))...)
+			solve := s.SolveWithStats
+			if tt.basic {
+				solve = func(ctx context.Context, n *big.Int) (FourInt, Stats, error) { return s.solve(ctx, n, false) }
+			}
+			got, stats, err := solve(tt.ctx, tt.n)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SolveWithStats() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				if !Verify(tt.n, got) {
+					t.Errorf("SolveWithStats() = %v does not verify for %v", got, tt.n)
+				}
+				if native := tt.n.BitLen() <= 128; native != (stats.Searches == 0) || native != (stats.Candidates == 0) {
+					t.Errorf("SolveWithStats() stats = %+v, want one search unless solved natively", stats)
+				}
+				return
+			}
+			if got[0] != nil {
+				t.Errorf("SolveWithStats() = %v, want an empty result", got)
+			}
+			if tt.wantErr == ErrSearchExhausted && (stats.Candidates == 0 || stats.Searches != 0) {
+				t.Errorf("SolveWithStats() stats = %+v, want partial stats", stats)
+			}
+			if s.MaxAttempts > 0 && stats.Candidates < s.MaxAttempts {
+				t.Errorf("SolveWithStats() stats.Candidates = %d, want >= %d", stats.Candidates, s.MaxAttempts)
+			}
+		})
+	}
+
+	s := NewSolver(WithPrimalityTest(func(*big.Int) bool { return false }), WithMaxAttempts(1000), WithNumRoutines(2))
+	if _, err := s.SolveVector(context.Background(), []*big.Int{n, big.NewInt(12345)}); !errors.Is(err, ErrSearchExhausted) {
+		t.Errorf("SolveVector() error = %v, want %v", err, ErrSearchExhausted)
+	}
+}
+
+func TestWithHedging(t *testing.T) {
+	threshold := new(big.Int).Lsh(big.NewInt(1), 256)
+	n := new(big.Int).Sub(threshold, big.NewInt(189))
+	s := NewSolver(WithFCMThreshold(threshold), WithHedging(8), WithNumRoutines(2))
+	got, stats, err := s.SolveWithStats(context.Background(), n)
+	if err != nil {
+		t.Fatalf("SolveWithStats() error = %v", err)
+	}
+	if !Verify(n, got) {
+		t.Errorf("SolveWithStats() = %v does not verify for %v", got, n)
+	}
+	if stats.Searches == 0 || stats.Routines != 2 {
+		t.Errorf("SolveWithStats() stats = %+v, want a search with one worker per algorithm", stats)
+	}
+
+	// Both algorithms exhaust their budget, and the stats cover both.
+	s = NewSolver(WithFCMThreshold(threshold), WithHedging(8), WithNumRoutines(2),
+		WithPrimalityTest(func(*big.Int) bool { return false }), WithMaxAttempts(1000))
+	_, stats, err = s.SolveWithStats(context.Background(), n)
+	if !errors.Is(err, ErrSearchExhausted) {
+		t.Fatalf("SolveWithStats() error = %v, want %v", err, ErrSearchExhausted)
+	}
+	if stats.Candidates < 2000 {
+		t.Errorf("SolveWithStats() stats.Candidates = %d, want >= 2000", stats.Candidates)
+	}
+
+	// A single routine cannot be split, so n is solved by the basic algorithm alone.
+	o := &recordingObserver{}
+	s = NewSolver(WithFCMThreshold(threshold), WithHedging(8), WithNumRoutines(1), WithObserver(o))
+	got, stats, err = s.SolveWithStats(context.Background(), n)
+	if err != nil || !Verify(n, got) {
+		t.Fatalf("SolveWithStats() = %v, %v does not verify for %v", got, err, n)
+	}
+	if stats.Routines != 1 || len(o.paths) != 1 || o.paths[0] != PathBasic {
+		t.Errorf("SolveWithStats() stats = %+v, paths = %v, want a single basic search", stats, o.paths)
+	}
+}
+
+func TestWithSeed(t *testing.T) {
+	n := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(189))
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "basic", opts: []Option{WithNumRoutines(1)}},
+		{name: "fcm", opts: []Option{WithNumRoutines(1), WithFCMThreshold(big.NewInt(1))}},
+		{name: "sieved", opts: []Option{WithNumRoutines(1), WithSearchMode(SievedSearch)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A second input checks that the streams of later searches are reproducible too.
+			inputs := []*big.Int{n, new(big.Int).Add(n, big.NewInt(2))}
+			var want []FourInt
+			for run := 0; run < 2; run++ {
+				s := NewSolver(append(tt.opts, WithSeed(42))...)
+				for i, x := range inputs {
+					got := s.Solve(x)
+					if !Verify(x, got) {
+						t.Fatalf("Solve() = %v does not verify for %v", got, x)
+					}
+					if run == 0 {
+						want = append(want, got)
+					} else if got.String() != want[i].String() {
+						t.Errorf("Solve(%v) = %v with the same seed, want %v", x, got.String(), want[i].String())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWithResultCache(t *testing.T) {
+	n := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(189))
+	m := new(big.Int).Add(n, big.NewInt(2))
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "all", opts: []Option{WithResultCache(1)}},
+		{name: "verified only", opts: []Option{WithResultCache(1), WithCacheVerifiedOnly()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSolver(tt.opts...)
+			first := s.Solve(n)
+			// Modifying a returned representation must not corrupt the cached entry.
+			want := first.Clone()
+			first[0].SetInt64(0)
+			got, stats, err := s.SolveWithStats(context.Background(), n)
+			if err != nil || !got.Equal(want) {
+				t.Fatalf("SolveWithStats() = %v, %v, want the cached %v", got.String(), err, want.String())
+			}
+			if stats != (Stats{CacheHits: 1}) {
+				t.Errorf("SolveWithStats() stats = %+v, want a single cache hit", stats)
+			}
+
+			// The cached n is served to SolveVector, and storing m evicts it from
+			// the cache of size 1.
+			if res, err := s.SolveVector(context.Background(), []*big.Int{m, n}); err != nil || !Verify(m, res[0]) || !res[1].Equal(want) {
+				t.Fatalf("SolveVector() = %v, %v", res, err)
+			}
+			if _, stats, err := s.SolveWithStats(context.Background(), n); err != nil || stats.CacheMisses != 1 {
+				t.Errorf("SolveWithStats() stats = %+v, %v, want a cache miss after eviction", stats, err)
+			}
+			if got := s.Stats(); got.CacheHits != 2 || got.CacheMisses != 3 {
+				t.Errorf("Stats() hits = %d, misses = %d, want 2 and 3", got.CacheHits, got.CacheMisses)
+			}
+			if got := s.CachedResults(); got != 1 {
+				t.Errorf("CachedResults() = %d, want 1", got)
+			}
+			s.ResetResultCache()
+			if got := s.CachedResults(); got != 0 {
+				t.Errorf("CachedResults() = %d after ResetResultCache, want 0", got)
+			}
+		})
+	}
+
+	s := NewSolver(WithResultCache(4), WithCacheVerifiedOnly())
+	s.cacheResult(n, NewFourInt(big1, big1, big1, big1))
+	if got := s.CachedResults(); got != 0 {
+		t.Errorf("CachedResults() = %d after caching an invalid representation, want 0", got)
+	}
+}
+
+func TestWithSelfVerify(t *testing.T) {
+	large := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(189))
+	bad := NewFourInt(big1, big1, big1, big1)
+	tests := []struct {
+		name         string
+		n            *big.Int
+		retries      int
+		badResults   int
+		wantErr      bool
+		wantFailures uint64
+	}{
+		{name: "valid", n: large, retries: 2},
+		{name: "retry basic", n: large, retries: 2, badResults: 2, wantFailures: 2},
+		{name: "retry native", n: big.NewInt(1 << 40), retries: 1, badResults: 1, wantFailures: 1},
+		{name: "exhausted", n: large, retries: 1, badResults: 2, wantErr: true, wantFailures: 2},
+		{name: "no retries", n: large, badResults: 1, wantErr: true, wantFailures: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSolver(WithSelfVerify(tt.retries))
+			calls := 0
+			s.testHookResult = func(_ *big.Int, fi FourInt) FourInt {
+				if calls++; calls <= tt.badResults {
+					return bad.Clone()
+				}
+				return fi
+			}
+			got, stats, err := s.SolveWithStats(context.Background(), tt.n)
+			if tt.wantErr {
+				if !errors.Is(err, ErrVerificationFailed) || got != (FourInt{}) {
+					t.Errorf("SolveWithStats() = %v, %v, want %v", got, err, ErrVerificationFailed)
+				}
+			} else if err != nil || !Verify(tt.n, got) {
+				t.Errorf("SolveWithStats() = %v, %v does not verify", got.String(), err)
+			}
+			if stats.VerifyFailures != tt.wantFailures || s.Stats().VerifyFailures != tt.wantFailures {
+				t.Errorf("VerifyFailures = %d, Stats().VerifyFailures = %d, want %d",
+					stats.VerifyFailures, s.Stats().VerifyFailures, tt.wantFailures)
+			}
+		})
+	}
+
+	if Verify(large, FourInt{}) {
+		t.Error("Verify() = true for nil components")
+	}
+
+	// A corrupted cache entry is detected and replaced, also in SolveVector.
+	s := NewSolver(WithSelfVerify(1), WithResultCache(4))
+	s.results.put(large, bad, s.ResultCacheSize)
+	res, err := s.SolveVector(context.Background(), []*big.Int{large})
+	if err != nil || !Verify(large, res[0]) {
+		t.Fatalf("SolveVector() = %v, %v", res, err)
+	}
+	if got, _ := s.results.get(large); !Verify(large, got) {
+		t.Errorf("cached result = %v, want the replacement", got.String())
+	}
+	if got := s.Stats().VerifyFailures; got != 1 {
+		t.Errorf("Stats().VerifyFailures = %d, want 1", got)
+	}
+}
@@ -0,0 +1,328 @@
+package lfs
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	comp "github.com/txaty/go-bigcomplex"
+)
+
+// ErrNegativeInput is returned when a negative integer is passed to the solver.
+var ErrNegativeInput = errors.New("lfs: negative input")
+
+// vectorPath identifies the search path taken by an input of SolveVector.
+type vectorPath int
+
+const (
+	vectorPathSmall vectorPath = iota // basic algorithm, nOdd below randLimitThreshold bits
+	vectorPathLarge                   // basic algorithm, large nOdd
+	vectorPathFCM                     // FCM algorithm
+)
+
+// vectorTask holds the search state of a single input of SolveVector.
+type vectorTask struct {
+	idx    int
+	nOdd   *big.Int
+	e      int
+	path   vectorPath
+	sieved bool // walk sieved windows instead of drawing random candidates
+	sp     *searchParams
+
+	done    atomic.Bool  // set once a valid candidate has been found
+	workers atomic.Int32 // number of workers currently searching this task
+}
+
+// vectorSetup caches the setup values shared by inputs of the same bit length.
+type vectorSetup struct {
+	primeProds map[int]*big.Int // bit length -> product of small primes
+	randLimits map[int]*big.Int // bit length -> random limit
+}
+
+// SolveVector computes the Lagrange four-square representations of all values in ns.
+// Setup work such as the prime product and the random bit length is shared between
+// inputs of the same bit length, and the candidate search is interleaved across
+// inputs: a worker picks up the next unsolved value as soon as it becomes idle and,
+// once every value has been started, helps with the values that are still pending.
+// The results are returned in the same order as ns. The attempt budget applies to
+// each input and the time budget to the whole call; once either is exhausted,
+// SolveVector returns ErrSearchExhausted.
+func (s *Solver) SolveVector(ctx context.Context, ns []*big.Int) (_ []FourInt, err error) {
+	for _, n := range ns {
+		if n.Sign() < 0 {
+			return nil, ErrNegativeInput
+		}
+	}
+	if s.Observer != nil {
+		start := time.Now()
+		for _, n := range ns {
+			s.Observer.OnSolveStart(n)
+		}
+		defer func() {
+			for _, n := range ns {
+				s.Observer.OnSolveEnd(n, Stats{}, time.Since(start), err)
+			}
+		}()
+	}
+	results := make([]FourInt, len(ns))
+	setup := vectorSetup{
+		primeProds: make(map[int]*big.Int),
+		randLimits: make(map[int]*big.Int),
+	}
+	var tasks []*vectorTask
+	for i, n := range ns {
+		if n.Sign() == 0 {
+			s.observePath(n, PathPrecomputed)
+			results[i] = NewFourInt(precomputedHurwitzGCRDs[0].ValInt())
+			continue
+		}
+		if fi, ok := s.cachedResult(n); ok {
+			s.observePath(n, PathCached)
+			results[i] = fi
+			continue
+		}
+		nOdd, e := extractOddComponent(n)
+		fcm := n.Cmp(s.FCMThreshold) >= 0
+		// As in Solve, a configured table takes precedence over the FCM algorithm.
+		if gcrd, ok := lookupSmallTable(nOdd, s.PrecomputeLimit); ok {
+			s.observePath(n, PathPrecomputed)
+			results[i] = composeFourInt(e, gcrd)
+			continue
+		}
+		if !fcm {
+			if nOdd.Cmp(bigPrecomputeLmt) <= 0 {
+				s.observePath(n, PathPrecomputed)
+				results[i] = composeFourInt(e, precomputedHurwitzGCRDs[nOdd.Int64()])
+				continue
+			}
+			s.observePath(n, PathBasic)
+		} else {
+			s.observePath(n, PathFCM)
+		}
+		tasks = append(tasks, setup.newTask(s, i, nOdd, e, fcm))
+	}
+	if len(tasks) == 0 {
+		return s.finishVector(ctx, ns, results, nil)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if s.TimeBudget > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, s.TimeBudget, ErrSearchExhausted)
+		defer cancelTimeout()
+	}
+	var (
+		cursor atomic.Int64
+		wg     sync.WaitGroup
+	)
+	maxBitLen := 0
+	for _, t := range tasks {
+		maxBitLen = max(maxBitLen, t.sp.preP.BitLen())
+	}
+	numRoutines := s.numRoutines(maxBitLen, s.NumRoutines)
+	s.stats.routines.Store(int64(numRoutines))
+	for i := 0; i < numRoutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var w *searchWorker
+			defer func() {
+				if w != nil {
+					w.logStop()
+				}
+			}()
+			for {
+				t := nextVectorTask(tasks, &cursor)
+				if t == nil {
+					return
+				}
+				// The scratch state is reused across tasks; only the parameters change.
+				if w == nil {
+					w = newSearchWorker(t.sp)
+				} else {
+					w.bind(t.sp)
+				}
+				t.workers.Add(1)
+				fi, ok := t.search(ctx, w)
+				w.flushStats()
+				if ok {
+					results[t.idx] = fi
+					s.complete(t.sp)
+					s.logSearch(t.sp, nil)
+				} else if t.sp.isExhausted() {
+					cancel(ErrSearchExhausted)
+				}
+				t.workers.Add(-1)
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return s.finishVector(ctx, ns, results, tasks)
+}
+
+// finishVector verifies the results of SolveVector with WithSelfVerify, replacing
+// wrong ones, and caches the results of the searched inputs and the replacements.
+func (s *Solver) finishVector(ctx context.Context, ns []*big.Int, results []FourInt, tasks []*vectorTask) ([]FourInt, error) {
+	if s.SelfVerify {
+		for i, n := range ns {
+			fi, stats, err := s.verifyResult(ctx, n, true, results[i], Stats{})
+			if err != nil {
+				return nil, err
+			}
+			if stats.VerifyFailures > 0 {
+				results[i] = fi
+				s.cacheResult(n, fi)
+			}
+		}
+	}
+	for _, t := range tasks {
+		s.cacheResult(ns[t.idx], results[t.idx])
+	}
+	return results, nil
+}
+
+// newTask prepares the search state for nOdd, reusing the setup values computed
+// for previous inputs of the same bit length.
+func (v *vectorSetup) newTask(s *Solver, idx int, nOdd *big.Int, e int, fcm bool) *vectorTask {
+	t := &vectorTask{idx: idx, nOdd: nOdd, e: e}
+	bitLen := nOdd.BitLen()
+	var preP, randLimit *big.Int
+	switch {
+	case fcm:
+		t.path = vectorPathFCM
+		preP = new(big.Int).Lsh(nOdd, 1)
+		// The random limit only depends on the bit length of preP = 2 * nOdd.
+		randLimit = v.randLimit(bitLen, func() *big.Int {
+			return new(big.Int).Lsh(big1, fcmComputeRandBitLen(preP))
+		})
+	case bitLen < randLimitThreshold:
+		t.path = vectorPathSmall
+		primeProd, ok := v.primeProds[bitLen]
+		if !ok {
+			primeProd = computePrimeProduct(nOdd)
+			v.primeProds[bitLen] = primeProd
+		}
+		preP = new(big.Int).Mul(primeProd, nOdd)
+		randLimit = computeInitialRandLimit(nOdd)
+		randLimit.Rsh(randLimit, 1)
+	default:
+		t.path = vectorPathLarge
+		preP = new(big.Int).Mul(tinyPrimeProd, nOdd)
+		randLimit = v.randLimit(bitLen, func() *big.Int {
+			return new(big.Int).Lsh(big1, uint(computeRandBitLength(bitLen)))
+		})
+	}
+	t.sp = s.newSearchParams(preP, randLimit)
+	if fcm {
+		t.sp.useFCMDensity()
+	}
+	t.sieved = s.SearchMode == SievedSearch
+	return t
+}
+
+// randLimit returns the cached random limit for bitLen, computing it if absent.
+func (v *vectorSetup) randLimit(bitLen int, compute func() *big.Int) *big.Int {
+	if rl, ok := v.randLimits[bitLen]; ok {
+		return rl
+	}
+	rl := compute()
+	v.randLimits[bitLen] = rl
+	return rl
+}
+
+// nextVectorTask returns the next task that has not been started yet. Once all
+// tasks have been started, it returns the unfinished task with the fewest workers,
+// or nil if every task is done.
+func nextVectorTask(tasks []*vectorTask, cursor *atomic.Int64) *vectorTask {
+	if i := int(cursor.Add(1) - 1); i < len(tasks) {
+		return tasks[i]
+	}
+	var best *vectorTask
+	for _, t := range tasks {
+		if t.done.Load() {
+			continue
+		}
+		if best == nil || t.workers.Load() < best.workers.Load() {
+			best = t
+		}
+	}
+	return best
+}
+
+// search runs the candidate search for the task until it is solved, either by
+// this worker or by another one, until ctx is cancelled, or until the attempt
+// budget of the task is exhausted. It reports true only to the worker that found
+// the solution.
+func (t *vectorTask) search(ctx context.Context, w *searchWorker) (FourInt, bool) {
+	for !t.done.Load() {
+		select {
+		case <-ctx.Done():
+			return FourInt{}, false
+		default:
+		}
+		if w.budgetExceeded() {
+			return FourInt{}, false
+		}
+		gcd, l, ok := t.pickCandidate(w)
+		if !ok {
+			continue
+		}
+		if !t.done.CompareAndSwap(false, true) {
+			break
+		}
+		var hurwitzGCRD *comp.HurwitzInt
+		if t.path == vectorPathFCM {
+			hurwitzGCRD = fcmFinalizeHurwitzGCRD(t.nOdd, l, gcd, t.sp.fastGCDBits)
+		} else {
+			hurwitzGCRD = finalizeHurwitzGCRD(t.nOdd, gcd, t.sp.fastGCDBits)
+		}
+		return composeFourInt(t.e, hurwitzGCRD), true
+	}
+	return FourInt{}, false
+}
+
+// pickCandidate performs a single candidate trial for the task using the worker's
+// scratch state and returns the valid Gaussian GCD, together with l for the FCM
+// path, if one was found.
+func (t *vectorTask) pickCandidate(w *searchWorker) (*comp.GaussianInt, *big.Int, bool) {
+	var (
+		s, p, l *big.Int
+		ok      bool
+		err     error
+	)
+	switch t.path {
+	case vectorPathSmall:
+		s, p, ok, err = w.pickCandidateS(big2, big1)
+	case vectorPathLarge:
+		if t.sieved {
+			s, p, ok, err = w.pickCandidateSLargeSieved()
+		} else {
+			s, p, ok, err = w.pickCandidateSLarge()
+		}
+	default:
+		s, p, l, ok, err = w.fcmPickCandidate()
+	}
+	if err != nil {
+		w.logCompositeCandidate(err)
+		return nil, nil, false
+	}
+	if !ok {
+		return nil, nil, false
+	}
+	gcd, err := w.splitCandidate(s, p)
+	if err != nil {
+		w.logCompositeCandidate(err)
+		return nil, nil, false
+	}
+	return gcd, l, true
+}
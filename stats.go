@@ -0,0 +1,103 @@
+package lfs
+
+import "sync/atomic"
+
+// Stats holds counters collected by the randomized candidate search.
+type Stats struct {
+	// Candidates is the number of candidate primes p drawn.
+	Candidates uint64
+	// Sieved is the number of candidates rejected by the small-prime sieve.
+	Sieved uint64
+	// Primes is the number of candidates that passed the primality test.
+	Primes uint64
+	// CompositeRetries is the number of candidates that passed the primality test
+	// but turned out to be composite, either without a square root of -1 or in
+	// the split into a sum of two squares, forcing the search to retry.
+	CompositeRetries uint64
+	// Searches is the number of randomized searches that found a solution.
+	Searches uint64
+	// Routines is the number of workers started by the most recent search, as
+	// chosen by the adaptive policy if enabled.
+	Routines int
+	// CacheHits is the number of inputs answered by the result cache.
+	CacheHits uint64
+	// CacheMisses is the number of inputs looked up in the result cache but not found.
+	CacheMisses uint64
+	// VerifyFailures is the number of results rejected by the self-verification
+	// of WithSelfVerify.
+	VerifyFailures uint64
+}
+
+// merge returns the sum of the counters of st and o, for searches run concurrently.
+func (st Stats) merge(o Stats) Stats {
+	return Stats{
+		Candidates:       st.Candidates + o.Candidates,
+		Sieved:           st.Sieved + o.Sieved,
+		Primes:           st.Primes + o.Primes,
+		CompositeRetries: st.CompositeRetries + o.CompositeRetries,
+		Searches:         st.Searches + o.Searches,
+		Routines:         st.Routines + o.Routines,
+		CacheHits:        st.CacheHits + o.CacheHits,
+		CacheMisses:      st.CacheMisses + o.CacheMisses,
+		VerifyFailures:   st.VerifyFailures + o.VerifyFailures,
+	}
+}
+
+// searchStats is the concurrency-safe counterpart of Stats updated by the workers.
+type searchStats struct {
+	candidates       atomic.Uint64
+	sieved           atomic.Uint64
+	primes           atomic.Uint64
+	compositeRetries atomic.Uint64
+	searches         atomic.Uint64
+	routines         atomic.Int64
+	cacheHits        atomic.Uint64
+	cacheMisses      atomic.Uint64
+	verifyFailures   atomic.Uint64
+}
+
+// add adds the counters of a worker.
+func (st *searchStats) add(ws *workerStats) {
+	st.candidates.Add(ws.candidates)
+	st.sieved.Add(ws.sieved)
+	st.primes.Add(ws.primes)
+	st.compositeRetries.Add(ws.compositeRetries)
+}
+
+// snapshot returns the current values of the counters.
+func (st *searchStats) snapshot() Stats {
+	return Stats{
+		Candidates:       st.candidates.Load(),
+		Sieved:           st.sieved.Load(),
+		Primes:           st.primes.Load(),
+		CompositeRetries: st.compositeRetries.Load(),
+		Searches:         st.searches.Load(),
+		Routines:         int(st.routines.Load()),
+		CacheHits:        st.cacheHits.Load(),
+		CacheMisses:      st.cacheMisses.Load(),
+		VerifyFailures:   st.verifyFailures.Load(),
+	}
+}
+
+// reset sets all counters to zero.
+func (st *searchStats) reset() {
+	st.candidates.Store(0)
+	st.sieved.Store(0)
+	st.primes.Store(0)
+	st.compositeRetries.Store(0)
+	st.searches.Store(0)
+	st.routines.Store(0)
+	st.cacheHits.Store(0)
+	st.cacheMisses.Store(0)
+	st.verifyFailures.Store(0)
+}
+
+// Stats returns the counters accumulated by all solves of the Solver so far.
+func (s *Solver) Stats() Stats {
+	return s.stats.snapshot()
+}
+
+// ResetStats resets the counters accumulated by the Solver.
+func (s *Solver) ResetStats() {
+	s.stats.reset()
+}
@@ -1,10 +1,26 @@
 package lfs
 
-import "math/big"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+)
+
+// ErrVerificationFailed is returned with WithSelfVerify if no result for an input
+// passed Verify within the configured retries.
+var ErrVerificationFailed = errors.New("lfs: result failed verification")
 
 // Verify checks if the four-square sum is equal to the original integer
 // i.e. target = w1^2 + w2^2 + w3^2 + w4^2
+// It reports false if a component is nil.
 func Verify(target *big.Int, fi FourInt) bool {
+	for _, x := range fi {
+		if x == nil {
+			return false
+		}
+	}
 	sum := iPool.Get().(*big.Int).SetInt64(0)
 	defer iPool.Put(sum)
 	opt := iPool.Get().(*big.Int)
@@ -14,3 +30,31 @@ func Verify(target *big.Int, fi FourInt) bool {
 	}
 	return sum.Cmp(target) == 0
 }
+
+// verifyResult returns fi if it is a representation of n. Otherwise, it solves n
+// again up to SelfVerifyRetries times and returns the first result that passes,
+// or ErrVerificationFailed. The native path is deterministic, so retries of small
+// inputs take the randomized search. The returned stats add the retries to stats.
+func (s *Solver) verifyResult(ctx context.Context, n *big.Int, fcm bool, fi FourInt, stats Stats) (FourInt, Stats, error) {
+	for retry := 0; ; retry++ {
+		if Verify(n, fi) {
+			return fi, stats, nil
+		}
+		stats.VerifyFailures++
+		s.stats.verifyFailures.Add(1)
+		s.logger().Error("lfs: result failed verification", slog.Int("bits", n.BitLen()), slog.Int("retry", retry))
+		if retry >= s.SelfVerifyRetries {
+			return FourInt{}, stats, fmt.Errorf("%w after %d retries", ErrVerificationFailed, retry)
+		}
+		var (
+			st  Stats
+			err error
+		)
+		fi, st, err = s.solveUncached(ctx, n, fcm && n.BitLen() > 128)
+		stats = stats.merge(st)
+		stats.Routines = st.Routines
+		if err != nil {
+			return FourInt{}, stats, err
+		}
+	}
+}